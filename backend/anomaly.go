@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// minAnomalySamples is how many successful probes a monitor needs before its
+// latency statistics are trusted for anomaly detection.
+const minAnomalySamples = 10
+
+// minAnomalyStdDevMs floors the standard deviation used for z-scores.
+// Latencies are whole milliseconds, so a very fast, very steady endpoint
+// would otherwise turn a 1 ms wobble into a huge z-score.
+const minAnomalyStdDevMs = 1.0
+
+// detectLatencyAnomaly scores the latest latency against the monitor's
+// exponentially weighted mean and standard deviation, then folds the sample
+// into the standard deviation using the same weight as the smoothed mean.
+// It must run before smoothResponseTime so the z-score compares against the
+// statistics from before this sample. When anomaly_z_score_threshold is set
+// and the latency is that many standard deviations above the mean, the
+// monitor is marked DEGRADED.
+func (mc *monitorChecker) detectLatencyAnomaly(monitor *Monitor, update map[string]interface{}) {
+	if category, _ := update["last_error_category"].(string); category != "" {
+		return
+	}
+	latency, ok := update["last_response_time_ms"].(int)
+	if !ok {
+		return
+	}
+	sample := float64(latency)
+	samples := monitor.ResponseTimeSamples + 1
+	update["response_time_samples"] = samples
+
+	if monitor.SmoothedResponseTimeMs <= 0 {
+		update["response_time_std_dev_ms"] = 0.0
+		update["last_z_score"] = 0.0
+		return
+	}
+
+	mean := monitor.SmoothedResponseTimeMs
+	stdDev := monitor.ResponseTimeStdDevMs
+	zScore := (sample - mean) / math.Max(stdDev, minAnomalyStdDevMs)
+	update["last_z_score"] = math.Round(zScore*100) / 100
+
+	diff := sample - mean
+	variance := (1 - mc.smoothing) * (stdDev*stdDev + mc.smoothing*diff*diff)
+	update["response_time_std_dev_ms"] = math.Sqrt(variance)
+
+	threshold := monitor.AnomalyZScoreThreshold
+	if threshold <= 0 || monitor.ResponseTimeSamples < minAnomalySamples || zScore <= threshold {
+		return
+	}
+	status, _ := update["status"].(string)
+	update["status"] = worseStatus(status, statusDegraded)
+	if message, _ := update["last_error"].(string); message == "" {
+		update["last_error"] = fmt.Sprintf("response time %d ms is %.1f standard deviations above the mean of %.0f ms", latency, zScore, mean)
+	}
+}
+
+// checkJitter marks the monitor DEGRADED when the weighted standard deviation
+// of its response time, as updated by detectLatencyAnomaly, exceeds
+// max_jitter_ms. This catches erratic latency that the mean smooths over.
+func checkJitter(monitor *Monitor, update map[string]interface{}) {
+	if monitor.MaxJitterMs <= 0 || monitor.ResponseTimeSamples < minAnomalySamples {
+		return
+	}
+	jitter, ok := update["response_time_std_dev_ms"].(float64)
+	if !ok || jitter <= monitor.MaxJitterMs {
+		return
+	}
+	status, _ := update["status"].(string)
+	update["status"] = worseStatus(status, statusDegraded)
+	if message, _ := update["last_error"].(string); message == "" {
+		update["last_error"] = fmt.Sprintf("response time jitter %.1f ms exceeds %.1f ms", jitter, monitor.MaxJitterMs)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += n
+	return n, err
+}
+
+// headerWireSize estimates the bytes a header block takes on the wire.
+func headerWireSize(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(": ") + len(value) + len("\r\n")
+		}
+	}
+	return size
+}
+
+// requestWireSize estimates the HTTP/1.1 bytes sent for req. Headers the
+// transport adds itself, such as a default User-Agent, are not included.
+func requestWireSize(req *http.Request) int {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	size := len(req.Method) + len(" ") + len(req.URL.RequestURI()) + len(" HTTP/1.1\r\n")
+	size += len("Host: ") + len(host) + len("\r\n")
+	size += headerWireSize(req.Header) + len("\r\n")
+	if req.ContentLength > 0 {
+		size += int(req.ContentLength)
+	}
+	return size
+}
+
+// responseWireSize estimates the bytes received for resp. bodyRead is the
+// number of body bytes actually read; when the body was not read, the
+// declared Content-Length is used instead.
+func responseWireSize(resp *http.Response, bodyRead int) int {
+	size := len(resp.Proto) + len(" ") + len(resp.Status) + len("\r\n") + headerWireSize(resp.Header) + len("\r\n")
+	if bodyRead == 0 && resp.ContentLength > 0 {
+		return size + int(resp.ContentLength)
+	}
+	return size + bodyRead
+}
+
+// intValue returns v as an int, or 0 when it is not one.
+func intValue(v interface{}) int {
+	n, _ := v.(int)
+	return n
+}
+
+// accumulateBandwidth adds the check's byte counts to the monitor's running
+// totals. The increment happens in the database so concurrent writers do not
+// lose counts.
+func accumulateBandwidth(update map[string]interface{}) {
+	if sent, ok := update["last_bytes_sent"].(int); ok && sent > 0 {
+		update["total_bytes_sent"] = gorm.Expr("total_bytes_sent + ?", sent)
+	}
+	if received, ok := update["last_bytes_received"].(int); ok && received > 0 {
+		update["total_bytes_received"] = gorm.Expr("total_bytes_received + ?", received)
+	}
+}
+
+// registerBandwidthRoutes exposes fleet-wide traffic totals and resets them.
+func registerBandwidthRoutes(router *gin.Engine, db *gorm.DB, keys apiKeys) {
+	router.GET("/status/bandwidth", authorize(keys, true), func(c *gin.Context) {
+		var totals struct {
+			Monitors      int64
+			BytesSent     int64
+			BytesReceived int64
+		}
+		err := ownedMonitors(c, db).
+			Select("COUNT(*) AS monitors, COALESCE(SUM(total_bytes_sent), 0) AS bytes_sent, COALESCE(SUM(total_bytes_received), 0) AS bytes_received").
+			Scan(&totals).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to compute bandwidth"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"monitors":       totals.Monitors,
+			"bytes_sent":     totals.BytesSent,
+			"bytes_received": totals.BytesReceived,
+			"bytes_total":    totals.BytesSent + totals.BytesReceived,
+		})
+	})
+
+	router.POST("/status/bandwidth/reset", authorize(keys, false), func(c *gin.Context) {
+		query := ownedMonitors(c, db)
+		if id := c.Query("monitor_id"); id != "" {
+			var monitor Monitor
+			if err := ownedMonitors(c, db).First(&monitor, id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+				return
+			}
+			query = query.Where("id = ?", monitor.ID)
+		}
+		result := query.UpdateColumns(map[string]interface{}{
+			"total_bytes_sent":     0,
+			"total_bytes_received": 0,
+			"bandwidth_reset_at":   time.Now(),
+		})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reset bandwidth"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Bandwidth counters reset", "monitors": result.RowsAffected})
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// startupCanary verifies that the host has working network egress by
+// requesting a known-good URL. Until the canary succeeds the instance reports
+// itself as not ready, because every monitor failure would otherwise look
+// like a fleet-wide outage. A nil canary is always ready.
+type startupCanary struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	ready   bool
+	lastErr string
+}
+
+func newStartupCanary(url string, client *http.Client) *startupCanary {
+	if url == "" {
+		return nil
+	}
+	return &startupCanary{url: url, client: client}
+}
+
+// probe requests the canary URL once. Any HTTP response counts as reachable.
+func (sc *startupCanary) probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sc.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := sc.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.ready = err == nil
+	sc.lastErr = ""
+	if err != nil {
+		sc.lastErr = err.Error()
+	}
+	return err
+}
+
+// run performs the boot-time probe. When required is true a failure is
+// returned to the caller so startup can be aborted; otherwise the canary keeps
+// retrying every interval in the background until it succeeds.
+func (sc *startupCanary) run(ctx context.Context, required bool, interval time.Duration) error {
+	err := sc.probe(ctx)
+	if err == nil {
+		slog.Info("startup canary reachable", "url", sc.url)
+		return nil
+	}
+	if required {
+		return fmt.Errorf("startup canary unreachable: %w", err)
+	}
+
+	slog.Warn("startup canary unreachable, reporting not ready", "url", sc.url, "error", err)
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if sc.probe(ctx) == nil {
+					slog.Info("startup canary reachable, instance ready", "url", sc.url)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// state reports readiness and the last canary error for /healthz.
+func (sc *startupCanary) state() (bool, string) {
+	if sc == nil {
+		return true, ""
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.ready, sc.lastErr
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+// defaultCertExpiryThresholdDays is how close to expiry a certificate turns
+// the monitor DEGRADED when neither CERT_EXPIRY_THRESHOLD_DAYS nor the
+// monitor's cert_expiry_threshold_days is set.
+const defaultCertExpiryThresholdDays = 14
+
+// maxCertExpiryThresholdDays bounds cert_expiry_threshold_days.
+const maxCertExpiryThresholdDays = 365
+
+// noCertExpiry is recorded in cert_expiry_days when the target did not
+// present a certificate.
+const noCertExpiry = -1
+
+// certExpiryThresholdFor returns the monitor's expiry threshold in days; 0
+// disables the check.
+func (mc *monitorChecker) certExpiryThresholdFor(monitor *Monitor) int {
+	if monitor.CertExpiryThresholdDays != nil {
+		return *monitor.CertExpiryThresholdDays
+	}
+	return mc.certExpiryThreshold
+}
+
+// certExpiryDays returns the whole days until the peer's leaf certificate
+// expires, 0 once it has, or noCertExpiry when the connection was not TLS.
+func certExpiryDays(state *tls.ConnectionState) int {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return noCertExpiry
+	}
+	return daysUntil(state.PeerCertificates[0].NotAfter)
+}
+
+// failedCertExpiryDays reads the expiry of a certificate the TLS handshake
+// rejected, so an expired certificate is still reported as such.
+func failedCertExpiryDays(err error) (int, bool) {
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) || len(certErr.UnverifiedCertificates) == 0 {
+		return 0, false
+	}
+	return daysUntil(certErr.UnverifiedCertificates[0].NotAfter), true
+}
+
+func daysUntil(t time.Time) int {
+	days := int(time.Until(t) / (24 * time.Hour))
+	if days < 0 {
+		return 0
+	}
+	return days
+}
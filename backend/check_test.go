@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestChecker returns a checker backed by a fresh SQLite database.
+func newTestChecker(t *testing.T) *monitorChecker {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "monitors.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Monitor{}, &CheckResult{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return newMonitorChecker(db)
+}
+
+// TestCheckMonitorDeadlineReleasesHungRequest checks a server that never
+// answers: MAX_CHECK_DURATION must end the check long before the monitor's
+// own timeout, close the connection, and leave no goroutine behind.
+func TestCheckMonitorDeadlineReleasesHungRequest(t *testing.T) {
+	var open atomic.Int32
+	released := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		released <- struct{}{}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			open.Add(1)
+		case http.StateClosed, http.StateHijacked:
+			open.Add(-1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	mc := newTestChecker(t)
+	mc.maxDuration = 300 * time.Millisecond
+	retries := 0
+	monitor := Monitor{Name: "hung", Type: monitorTypeHTTP, URL: server.URL, Enabled: true, TimeoutSeconds: 30, RetryCount: &retries}
+	if err := mc.db.Create(&monitor).Error; err != nil {
+		t.Fatal(err)
+	}
+	baseline := runtime.NumGoroutine()
+
+	start := time.Now()
+	mc.checkMonitor(context.Background(), &monitor)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("check took %s, want about %s", elapsed, mc.maxDuration)
+	}
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the server's handler was never released")
+	}
+	waitFor(t, "the connection to close", func() bool { return open.Load() == 0 })
+	waitFor(t, "goroutines to settle", func() bool { return runtime.NumGoroutine() <= baseline })
+
+	var saved Monitor
+	if err := mc.db.First(&saved, monitor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.LastErrorCategory != errorCategoryTimeout {
+		t.Errorf("last_error_category = %q, want %q", saved.LastErrorCategory, errorCategoryTimeout)
+	}
+}
+
+// waitFor polls cond for up to two seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestFallbackKeepsShareOfDeadline checks a primary that never answers: it
+// must stop early enough for a working fallback to serve the check.
+func TestFallbackKeepsShareOfDeadline(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fallback.Close()
+
+	mc := newTestChecker(t)
+	mc.maxDuration = 2 * time.Second
+	monitor := Monitor{Name: "pair", Type: monitorTypeHTTP, URL: primary.URL, FallbackURL: fallback.URL, Enabled: true}
+	if err := mc.db.Create(&monitor).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mc.checkMonitor(context.Background(), &monitor)
+
+	var saved Monitor
+	if err := mc.db.First(&saved, monitor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != statusDegraded || saved.LastEndpoint != endpointFallback {
+		t.Errorf("status %q from %q, want %q from %q: %s", saved.Status, saved.LastEndpoint, statusDegraded, endpointFallback, saved.LastError)
+	}
+}
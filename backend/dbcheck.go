@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const monitorTypeDB = "db"
+
+// defaultDBQuery runs when a db monitor has no db_query.
+const defaultDBQuery = "SELECT 1"
+
+// checkDatabase connects to the DSN in a db monitor's URL and runs its query.
+// The DSN usually embeds credentials, so it is never logged.
+func (mc *monitorChecker) checkDatabase(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	update := map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            "",
+		"last_error_category":   "",
+	}
+
+	driverName, dsn, err := databaseDSN(monitor.URL)
+	if err != nil {
+		slog.Error("unsupported database URL", "monitor_id", monitor.ID, "error", err)
+		update["last_error"] = err.Error()
+		update["last_error_category"] = errorCategoryOther
+		return update
+	}
+
+	query := monitor.DBQuery
+	if query == "" {
+		query = defaultDBQuery
+	}
+
+	conn, err := sql.Open(driverName, dsn)
+	if err == nil {
+		defer conn.Close()
+		start := time.Now()
+		var rows *sql.Rows
+		rows, err = conn.QueryContext(ctx, query)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+		}
+		update["last_response_time_ms"] = int(time.Since(start) / time.Millisecond)
+	}
+	if err != nil {
+		message := redactDSNError(err, monitor.URL)
+		slog.Error("database check failed", "monitor_id", monitor.ID, "error", message)
+		update["last_error"] = message
+		update["last_error_category"] = classifyRequestError(err)
+		return update
+	}
+
+	update["status"] = statusHealthy
+	return update
+}
+
+// databaseDSN maps a monitor URL to a registered database/sql driver and the
+// DSN format that driver expects. Only postgres and mysql are supported.
+func databaseDSN(raw string) (string, string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid database URL")
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "postgres", "postgresql":
+		return "pgx", raw, nil
+	case "mysql":
+		cfg := mysql.NewConfig()
+		cfg.User = parsed.User.Username()
+		cfg.Passwd, _ = parsed.User.Password()
+		cfg.Net = "tcp"
+		cfg.Addr = parsed.Host
+		cfg.DBName = strings.TrimPrefix(parsed.Path, "/")
+		if len(parsed.Query()) > 0 {
+			cfg.Params = make(map[string]string)
+			for key, values := range parsed.Query() {
+				cfg.Params[key] = values[len(values)-1]
+			}
+		}
+		return "mysql", cfg.FormatDSN(), nil
+	default:
+		return "", "", fmt.Errorf("unsupported database scheme %q (use postgres or mysql)", parsed.Scheme)
+	}
+}
+
+// redactDSNError strips the password from a driver error before it is logged
+// or stored.
+func redactDSNError(err error, raw string) string {
+	message := err.Error()
+	if parsed, parseErr := url.Parse(raw); parseErr == nil {
+		if password, ok := parsed.User.Password(); ok && password != "" {
+			message = strings.ReplaceAll(message, password, "xxxxx")
+		}
+	}
+	return message
+}
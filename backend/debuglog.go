@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sensitiveHeaders are always redacted from debug logs. Headers whose names
+// mention tokens, secrets, passwords, or keys are redacted as well.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// checkTrace records connection phase timestamps for a monitor with
+// debug_logging enabled.
+type checkTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newCheckTrace() *checkTrace {
+	return &checkTrace{start: time.Now()}
+}
+
+func (t *checkTrace) clientTrace() *httptrace.ClientTrace {
+	mark := func(field *time.Time) {
+		t.mu.Lock()
+		*field = time.Now()
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart: func(string, string) { mark(&t.connectStart) },
+		ConnectDone:  func(string, string, error) { mark(&t.connectDone) },
+		TLSHandshakeStart: func() {
+			mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	}
+}
+
+// timings returns the duration of each observed phase in milliseconds.
+func (t *checkTrace) timings() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	phases := map[string]float64{}
+	add := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			phases[name] = float64(to.Sub(from).Microseconds()) / 1000
+		}
+	}
+	add("dns_ms", t.dnsStart, t.dnsDone)
+	add("connect_ms", t.connectStart, t.connectDone)
+	add("tls_ms", t.tlsStart, t.tlsDone)
+	add("first_byte_ms", t.start, t.firstByte)
+	add("total_ms", t.start, time.Now())
+	return phases
+}
+
+// tracePhase is the moment a request reached one of its phases.
+type tracePhase struct {
+	name string
+	at   time.Time
+}
+
+// phases lists the observed phase boundaries in the order they occur.
+func (t *checkTrace) phases() []tracePhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var phases []tracePhase
+	add := func(name string, at time.Time) {
+		if !at.IsZero() {
+			phases = append(phases, tracePhase{name: name, at: at})
+		}
+	}
+	add("dns.start", t.dnsStart)
+	add("dns.done", t.dnsDone)
+	add("connect.start", t.connectStart)
+	add("connect.done", t.connectDone)
+	add("tls.start", t.tlsStart)
+	add("tls.done", t.tlsDone)
+	add("first_byte", t.firstByte)
+	return phases
+}
+
+// log writes one JSON line describing the request, the response or error,
+// and the phase timings. Sensitive headers are redacted.
+func (t *checkTrace) log(monitor *Monitor, ip string, req *http.Request, resp *http.Response, err error) {
+	entry := map[string]interface{}{
+		"monitor_id":      monitor.ID,
+		"method":          req.Method,
+		"url":             req.URL.Redacted(),
+		"request_headers": redactHeaders(req.Header),
+		"timings":         t.timings(),
+	}
+	t.mu.Lock()
+	entry["reused_connection"] = t.reused
+	t.mu.Unlock()
+	if ip != "" {
+		entry["ip"] = ip
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+	if resp != nil {
+		entry["status_code"] = resp.StatusCode
+		entry["protocol"] = resp.Proto
+		entry["response_headers"] = redactHeaders(resp.Header)
+	}
+	encoded, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		slog.Error("debug log failed", "monitor_id", monitor.ID, "error", marshalErr)
+		return
+	}
+	slog.Info("check trace", "monitor_id", monitor.ID, "trace", string(encoded))
+}
+
+func redactHeaders(headers http.Header) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if isSensitiveHeader(name) {
+			redacted[name] = "[REDACTED]"
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+func isSensitiveHeader(name string) bool {
+	canonical := http.CanonicalHeaderKey(name)
+	if sensitiveHeaders[canonical] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, marker := range []string{"token", "secret", "password", "key", "auth"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+// maxHistoryPoints bounds the max_points query parameter of the history
+// endpoint.
+const maxHistoryPoints = 1000
+
+// downsampleHistory reduces results, given in chronological order, to at
+// most maxPoints by averaging neighbouring checks into buckets. Buckets never
+// span a status change while there are points to spare, so every transition
+// keeps its exact time; when there are more transitions than points, each
+// bucket takes the worst status it covers so outages stay visible.
+func downsampleHistory(results []CheckResult, maxPoints int) []CheckResult {
+	if len(results) <= maxPoints {
+		return results
+	}
+
+	// runs holds the start index of every stretch of equal statuses.
+	runs := []int{0}
+	for i := 1; i < len(results); i++ {
+		if results[i].Status != results[i-1].Status {
+			runs = append(runs, i)
+		}
+	}
+	if len(runs) > maxPoints {
+		return bucketResults(results, maxPoints)
+	}
+
+	// Every run keeps one point; the rest are shared in proportion to length.
+	spare, extra := maxPoints-len(runs), len(results)-len(runs)
+	sampled := make([]CheckResult, 0, maxPoints)
+	for i, start := range runs {
+		end := len(results)
+		if i+1 < len(runs) {
+			end = runs[i+1]
+		}
+		points := 1 + spare*(end-start-1)/extra
+		sampled = append(sampled, bucketResults(results[start:end], points)...)
+	}
+	return sampled
+}
+
+// bucketResults splits results into evenly sized buckets and merges each
+// into one point.
+func bucketResults(results []CheckResult, buckets int) []CheckResult {
+	if buckets >= len(results) {
+		return results
+	}
+	merged := make([]CheckResult, 0, buckets)
+	for b := 0; b < buckets; b++ {
+		start, end := b*len(results)/buckets, (b+1)*len(results)/buckets
+		merged = append(merged, mergeResults(results[start:end]))
+	}
+	return merged
+}
+
+// mergeResults represents several checks as one: it is dated at the first of
+// them, reports their average response time, and carries the status, code,
+// and error of the worst.
+func mergeResults(results []CheckResult) CheckResult {
+	merged := results[0]
+	total := 0
+	for _, result := range results {
+		total += result.ResponseTimeMs
+		if worseStatus(merged.Status, result.Status) != merged.Status {
+			merged.Status = result.Status
+			merged.ResponseCode = result.ResponseCode
+			merged.Error = result.Error
+			merged.ErrorCategory = result.ErrorCategory
+			merged.FailedStep = result.FailedStep
+			merged.Endpoint = result.Endpoint
+		}
+	}
+	merged.ResponseTimeMs = total / len(results)
+	merged.Samples = len(results)
+	return merged
+}
+
+// reverseResults reverses results in place, turning the newest-first order of
+// the history endpoint into chronological order and back.
+func reverseResults(results []CheckResult) {
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// smtpImplicitTLSPort is the port on which the connection starts with TLS;
+// on any other port the notifier requires STARTTLS.
+const smtpImplicitTLSPort = "465"
+
+// smtpTimeout bounds the delivery of one email.
+const smtpTimeout = 30 * time.Second
+
+// emailNotifier sends an email when a monitor goes UNHEALTHY and another
+// when it recovers from an outage. Connections always use TLS. A nil
+// notifier does nothing.
+type emailNotifier struct {
+	host     string
+	port     string
+	user     string
+	password string
+	from     string
+	to       []string
+}
+
+// newEmailNotifier returns a notifier, or nil unless host, port, from, and
+// at least one recipient are set. user and password are optional, for
+// relays that need no authentication.
+func newEmailNotifier(host, port, user, password, from string, to []string) *emailNotifier {
+	if host == "" || port == "" || from == "" || len(to) == 0 {
+		return nil
+	}
+	return &emailNotifier{host: host, port: port, user: user, password: password, from: from, to: to}
+}
+
+// email is one alert ready to send.
+type email struct {
+	subject string
+	body    string
+}
+
+// message returns the email for a monitor moving from previous to status,
+// or false when the change needs none. A return to HEALTHY only counts as a
+// recovery when the monitor was UNHEALTHY since its last healthy check,
+// and the email says how long ago that check was.
+func (n *emailNotifier) message(db *gorm.DB, monitor *Monitor, previous, status string, update map[string]interface{}) (email, bool) {
+	if n == nil {
+		return email{}, false
+	}
+	checkedAt, _ := update["last_check"].(time.Time)
+	code, _ := update["last_response_code"].(int)
+	latency, _ := update["last_response_time_ms"].(int)
+	var body strings.Builder
+	fmt.Fprintf(&body, "Monitor: %s\nURL: %s\nStatus: %s (was %s)\n", monitor.Name, monitor.redactedURL(), status, previous)
+	fmt.Fprintf(&body, "Checked at: %s\n", checkedAt.Format(time.RFC1123Z))
+	if code != 0 {
+		fmt.Fprintf(&body, "Response code: %d\n", code)
+	}
+	fmt.Fprintf(&body, "Response time: %d ms\n", latency)
+
+	switch {
+	case status == statusUnhealthy && previous != statusUnhealthy:
+		if lastError, _ := update["last_error"].(string); lastError != "" {
+			fmt.Fprintf(&body, "Error: %s\n", lastError)
+		}
+		if monitor.FailureMessage != "" {
+			fmt.Fprintf(&body, "\n%s\n", monitor.FailureMessage)
+		}
+		return email{subject: fmt.Sprintf("[UselessMonitor] %s is UNHEALTHY", monitor.Name), body: body.String()}, true
+	case status == statusHealthy && isFailing(previous):
+		var lastHealthy CheckResult
+		query := db.Where("monitor_id = ? AND status = ? AND checked_at < ?", monitor.ID, statusHealthy, checkedAt).Order("checked_at desc").Limit(1).Find(&lastHealthy)
+		if query.Error != nil {
+			slog.Error("recovery email history query failed", "monitor_id", monitor.ID, "error", query.Error)
+			return email{}, false
+		}
+		since := monitor.CreatedAt
+		if query.RowsAffected > 0 {
+			since = lastHealthy.CheckedAt
+		}
+		var outages int64
+		if err := db.Model(&CheckResult{}).Where("monitor_id = ? AND status = ? AND checked_at > ?", monitor.ID, statusUnhealthy, since).Count(&outages).Error; err != nil || outages == 0 {
+			return email{}, false
+		}
+		down := checkedAt.Sub(since).Round(time.Second)
+		fmt.Fprintf(&body, "Down for: %s (since %s)\n", down, since.Format(time.RFC1123Z))
+		return email{subject: fmt.Sprintf("[UselessMonitor] %s recovered after %s", monitor.Name, down), body: body.String()}, true
+	}
+	return email{}, false
+}
+
+// send delivers message to every recipient, over implicit TLS on port 465
+// and STARTTLS otherwise.
+func (n *emailNotifier) send(monitorID uint, message email) {
+	if err := n.deliver(message); err != nil {
+		slog.Error("email failed", "monitor_id", monitorID, "error", err)
+	}
+}
+
+func (n *emailNotifier) deliver(message email) error {
+	address := net.JoinHostPort(n.host, n.port)
+	tlsConfig := &tls.Config{ServerName: n.host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if n.port == smtpImplicitTLSPort {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if n.port != smtpImplicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not offer STARTTLS", address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if n.user != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.user, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, recipient := range n.to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	headers := []string{
+		"From: " + n.from,
+		"To: " + strings.Join(n.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(message.subject)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	content := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(message.body, "\n", "\r\n")
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is advertised by checks that inspect the body, so content
+// checks see what a browser would be served.
+const acceptEncoding = "gzip, deflate, br"
+
+// readDecodedBody reads at most maxBodyBytes of the decoded response body.
+// The limit applies after decompression, so a small compressed payload cannot
+// expand beyond it. Bodies Go already decompressed are read as-is.
+func readDecodedBody(resp *http.Response) ([]byte, error) {
+	reader := io.Reader(resp.Body)
+	if !resp.Uncompressed {
+		encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+		// Encodings are listed in the order they were applied; undo them in
+		// reverse.
+		for i := len(encodings) - 1; i >= 0; i-- {
+			decoded, err := decodeContent(strings.ToLower(strings.TrimSpace(encodings[i])), reader)
+			if err != nil {
+				return nil, err
+			}
+			reader = decoded
+		}
+	}
+	return io.ReadAll(io.LimitReader(reader, maxBodyBytes))
+}
+
+func decodeContent(encoding string, reader io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "", "identity":
+		return reader, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(reader)
+	case "deflate":
+		return flate.NewReader(reader), nil
+	case "br":
+		return brotli.NewReader(reader), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+}
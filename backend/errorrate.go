@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxErrorRateWindow bounds how many recent checks error_rate_window may span.
+const maxErrorRateWindow = 1000
+
+// Outcome markers kept in Monitor.RecentOutcomes, oldest first.
+const (
+	outcomeSuccess = '1'
+	outcomeFailure = '0'
+)
+
+// applyErrorRate judges the monitor on its failure rate over the last
+// error_rate_window checks instead of on the latest result alone. A single
+// UNHEALTHY probe is replaced by the status the rate warrants; DEGRADED
+// verdicts from other checks are kept when they are worse.
+func applyErrorRate(monitor *Monitor, update map[string]interface{}) {
+	status, ok := update["status"].(string)
+	if !ok || monitor.ErrorRateWindow <= 0 {
+		return
+	}
+
+	outcome := outcomeSuccess
+	if status == statusUnhealthy {
+		outcome = outcomeFailure
+	}
+	window := monitor.RecentOutcomes + string(outcome)
+	if len(window) > monitor.ErrorRateWindow {
+		window = window[len(window)-monitor.ErrorRateWindow:]
+	}
+	rate := float64(strings.Count(window, string(outcomeFailure))) * 100 / float64(len(window))
+	update["recent_outcomes"] = window
+	update["last_error_rate"] = rate
+
+	rateStatus := statusHealthy
+	switch {
+	case monitor.ErrorRateUnhealthyPercent > 0 && rate >= monitor.ErrorRateUnhealthyPercent:
+		rateStatus = statusUnhealthy
+	case monitor.ErrorRateDegradedPercent > 0 && rate >= monitor.ErrorRateDegradedPercent:
+		rateStatus = statusDegraded
+	}
+	if status == statusUnhealthy {
+		update["status"] = rateStatus
+	} else {
+		update["status"] = worseStatus(status, rateStatus)
+	}
+	if lastError, _ := update["last_error"].(string); rateStatus != statusHealthy && lastError == "" {
+		update["last_error"] = fmt.Sprintf("%.1f%% of the last %d checks failed", rate, len(window))
+	}
+}
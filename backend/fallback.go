@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Endpoints recorded in last_endpoint for monitors with a fallback_url.
+const (
+	endpointPrimary  = "primary"
+	endpointFallback = "fallback"
+)
+
+// fallbackOwnKeys are columns that describe the primary endpoint alone, so a
+// check served by the fallback leaves them as they were.
+var fallbackOwnKeys = []string{"last_cert_fingerprint", "cert_fingerprint", "cert_expiry_days"}
+
+// validateFallbackURL accepts an empty fallback, or a URL for a monitor whose
+// checks are HTTP requests.
+func validateFallbackURL(typeValue, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if !(&Monitor{Type: typeValue}).probesHTTP() {
+		return fmt.Errorf("fallback_url is only supported by HTTP monitors")
+	}
+	if err := validateURL(raw); err != nil {
+		return fmt.Errorf("fallback_url is not a valid URL")
+	}
+	return nil
+}
+
+// primaryContext bounds the primary endpoint's attempts when the monitor has
+// a fallback_url, so the check's deadline keeps room for the fallback: its
+// whole retry budget, but never more than half the deadline. Without a
+// fallback the primary gets all of ctx.
+func (mc *monitorChecker) primaryContext(ctx context.Context, monitor *Monitor) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if monitor.FallbackURL == "" || !ok {
+		return ctx, func() {}
+	}
+	reserve := mc.checkBudget(monitor)
+	if half := mc.maxDuration / 2; reserve > half {
+		reserve = half
+	}
+	return context.WithDeadline(ctx, deadline.Add(-reserve))
+}
+
+// tryFallback checks the monitor's fallback_url when the primary endpoint
+// was UNHEALTHY. A fallback that answers makes the monitor at most DEGRADED,
+// since it is up but served by the backup; one that also fails leaves the
+// primary's result, with both errors. target is the monitor as the primary
+// was probed, with its secrets resolved, and ctx the whole check's, of
+// which primaryContext kept the fallback's share.
+func (mc *monitorChecker) tryFallback(ctx context.Context, monitor, target *Monitor, update map[string]interface{}) map[string]interface{} {
+	if monitor.FallbackURL == "" || update == nil {
+		return update
+	}
+	update["last_endpoint"] = endpointPrimary
+	if update["status"] != statusUnhealthy || update["last_error_category"] == errorCategoryCanceled {
+		return update
+	}
+	primaryError, _ := update["last_error"].(string)
+	fallbackURL, err := mc.secrets.expand(monitor.FallbackURL)
+	if err != nil {
+		update["last_error"] = fmt.Sprintf("%s; fallback not checked: %v", primaryError, err)
+		return update
+	}
+	backup := *target
+	backup.URL = fallbackURL
+	// The pinned certificate and the content baseline belong to the primary.
+	backup.CertPinned = false
+	backup.ChangeThresholdPercent = 0
+	slog.Warn("primary endpoint failed; checking the fallback", "monitor_id", monitor.ID, "error", primaryError)
+	fallback := mc.probeWithRetries(ctx, &backup)
+	if fallback == nil {
+		return update
+	}
+	fallback["last_bytes_sent"] = intValue(update["last_bytes_sent"]) + intValue(fallback["last_bytes_sent"])
+	fallback["last_bytes_received"] = intValue(update["last_bytes_received"]) + intValue(fallback["last_bytes_received"])
+	fallbackError, _ := fallback["last_error"].(string)
+	if fallback["status"] == statusUnhealthy || fallback["last_error_category"] == errorCategoryCanceled {
+		update["last_error"] = fmt.Sprintf("%s; fallback also failed: %s", primaryError, fallbackError)
+		update["last_bytes_sent"] = fallback["last_bytes_sent"]
+		update["last_bytes_received"] = fallback["last_bytes_received"]
+		return update
+	}
+	for _, key := range fallbackOwnKeys {
+		delete(fallback, key)
+	}
+	status, _ := fallback["status"].(string)
+	fallback["status"] = worseStatus(status, statusDegraded)
+	fallback["last_endpoint"] = endpointFallback
+	fallback["last_error"] = "served by fallback; primary failed: " + primaryError
+	if fallbackError != "" {
+		fallback["last_error"] = fmt.Sprintf("%s; fallback: %s", fallback["last_error"], fallbackError)
+	}
+	return fallback
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+)
+
+const monitorTypeFeed = "feed"
+
+// defaultFeedMaxAge applies to feed monitors without feed_max_age_seconds.
+const defaultFeedMaxAge = 24 * time.Hour
+
+// feedDocument covers RSS 2.0, RSS 1.0 (RDF), and Atom documents. Only the
+// fields carrying entry timestamps are decoded.
+type feedDocument struct {
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItem `xml:"item"`
+	Entries []struct {
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+	} `xml:"entry"`
+}
+
+type feedItem struct {
+	PubDate string `xml:"pubDate"`
+	DCDate  string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// latestFeedEntry returns the newest entry timestamp found in an RSS or Atom body.
+func latestFeedEntry(body []byte) (time.Time, error) {
+	var doc feedDocument
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return time.Time{}, err
+	}
+
+	var candidates []string
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		candidates = append(candidates, item.PubDate, item.DCDate)
+	}
+	for _, entry := range doc.Entries {
+		candidates = append(candidates, entry.Updated, entry.Published)
+	}
+
+	var latest time.Time
+	for _, raw := range candidates {
+		if parsed, ok := parseFeedDate(raw); ok && parsed.After(latest) {
+			latest = parsed
+		}
+	}
+	if latest.IsZero() {
+		return latest, errors.New("feed has no dated entries")
+	}
+	return latest, nil
+}
+
+func parseFeedDate(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range feedDateLayouts {
+		if parsed, err := time.Parse(layout, raw); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultFleetWebhookCooldown is the least time between two fleet
+// notifications when FLEET_WEBHOOK_COOLDOWN is unset.
+const defaultFleetWebhookCooldown = 5 * time.Minute
+
+// fleetWebhookAttempts bounds deliveries of one fleet notification.
+const fleetWebhookAttempts = 3
+
+// fleetSignatureHeader carries the HMAC-SHA256 of the body when
+// FLEET_WEBHOOK_SECRET is set.
+const fleetSignatureHeader = "X-UselessMonitor-Signature"
+
+// fleetEvent is the body of a fleet webhook: the aggregate status of every
+// enabled monitor, whoever owns it, and how many monitors are in each state.
+type fleetEvent struct {
+	Event             string    `json:"event"`
+	Status            string    `json:"status"`
+	PreviousStatus    string    `json:"previous_status"`
+	Monitors          int       `json:"monitors"`
+	HealthyMonitors   int       `json:"healthy_monitors"`
+	DegradedMonitors  int       `json:"degraded_monitors"`
+	UnhealthyMonitors int       `json:"unhealthy_monitors"`
+	UnknownMonitors   int       `json:"unknown_monitors"`
+	ChangedAt         time.Time `json:"changed_at"`
+}
+
+// fleetWebhook posts one notification when the rollup of all monitors
+// changes, as GET /status would report it across every owner. The first
+// status seen after startup (or after winning leadership) is taken as the
+// baseline without a notification. Within the cooldown after a delivery a
+// new status is held back; it is sent once the cooldown ends if it still
+// differs from the last one reported, so a brief flap produces nothing. A
+// nil webhook does nothing.
+type fleetWebhook struct {
+	client   *http.Client
+	url      string
+	secret   string
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	reported string
+	sentAt   time.Time
+}
+
+func newFleetWebhook(url, secret string, cooldown time.Duration) *fleetWebhook {
+	if url == "" {
+		return nil
+	}
+	return &fleetWebhook{client: &http.Client{Timeout: 10 * time.Second}, url: url, secret: secret, cooldown: cooldown}
+}
+
+// observe computes the fleet status and returns the event to send, if any.
+func (fw *fleetWebhook) observe(db *gorm.DB) (fleetEvent, bool) {
+	if fw == nil {
+		return fleetEvent{}, false
+	}
+	var statuses []string
+	if err := db.Model(&Monitor{}).Where("enabled = ?", true).Pluck("status", &statuses).Error; err != nil {
+		slog.Error("fleet status query failed", "error", err)
+		return fleetEvent{}, false
+	}
+	event := fleetEvent{Event: "fleet_status_changed", Monitors: len(statuses), ChangedAt: time.Now()}
+	for i, status := range statuses {
+		statuses[i] = strings.ToUpper(status)
+		switch statuses[i] {
+		case statusHealthy:
+			event.HealthyMonitors++
+		case statusDegraded:
+			event.DegradedMonitors++
+		case statusUnhealthy:
+			event.UnhealthyMonitors++
+		default:
+			event.UnknownMonitors++
+		}
+	}
+	event.Status = rollupStatus(statuses)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.reported == "" {
+		fw.reported = event.Status
+		return fleetEvent{}, false
+	}
+	if event.Status == fw.reported || time.Since(fw.sentAt) < fw.cooldown {
+		return fleetEvent{}, false
+	}
+	event.PreviousStatus = fw.reported
+	fw.reported = event.Status
+	fw.sentAt = event.ChangedAt
+	return event, true
+}
+
+// reset forgets the baseline, for an instance that stops checking.
+func (fw *fleetWebhook) reset() {
+	if fw == nil {
+		return
+	}
+	fw.mu.Lock()
+	fw.reported = ""
+	fw.mu.Unlock()
+}
+
+// send delivers event, signing it when a secret is configured.
+func (fw *fleetWebhook) send(event fleetEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("fleet webhook not sent", "previous_status", event.PreviousStatus, "status", event.Status, "error", err)
+		return
+	}
+	header := http.Header{}
+	if fw.secret != "" {
+		mac := hmac.New(sha256.New, []byte(fw.secret))
+		mac.Write(body)
+		header.Set(fleetSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	if err := postWithRetries(fw.client, fw.url, body, header, fleetWebhookAttempts); err != nil {
+		slog.Error("fleet webhook failed", "previous_status", event.PreviousStatus, "status", event.Status, "error", err)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MonitorGroup is a named, optionally nested collection of monitors used to
+// build hierarchical status pages. A monitor may belong to several groups.
+type MonitorGroup struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	Name       string `json:"name" gorm:"not null"`
+	ParentID   *uint  `json:"parent_id" gorm:"index"`
+	OwnerKey   string `json:"-" gorm:"index"`
+	MonitorIDs []uint `json:"monitor_ids" gorm:"-"`
+}
+
+// MonitorGroupMember links a monitor to a group.
+type MonitorGroupMember struct {
+	GroupID   uint `gorm:"primaryKey"`
+	MonitorID uint `gorm:"primaryKey;index"`
+}
+
+// groupRequest captures the fields of a group. On update every field is
+// optional; a parent_id of 0 moves the group to the top level.
+type groupRequest struct {
+	Name       *string `json:"name"`
+	ParentID   *uint   `json:"parent_id"`
+	MonitorIDs *[]uint `json:"monitor_ids"`
+}
+
+// groupStatus is one node of the rolled-up status tree.
+type groupStatus struct {
+	ID              uint                 `json:"id"`
+	Name            string               `json:"name"`
+	Status          string               `json:"status"`
+	TotalMonitors   int                  `json:"total_monitors"`
+	HealthyMonitors int                  `json:"healthy_monitors"`
+	Monitors        []groupMonitorStatus `json:"monitors"`
+	Groups          []groupStatus        `json:"groups"`
+}
+
+type groupMonitorStatus struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Paused monitors are listed but left out of the rollup.
+	Paused bool `json:"paused,omitempty"`
+}
+
+var errGroupCycle = errors.New("parent_id would create a cycle")
+
+// ownedGroups scopes a group query to the caller, like ownedMonitors.
+func ownedGroups(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if c.GetBool(superadminContextKey) {
+		return db.Model(&MonitorGroup{})
+	}
+	if c.GetBool(publicContextKey) {
+		// Public keys own nothing, so they see none.
+		return db.Model(&MonitorGroup{}).Where("1 = 0")
+	}
+	return db.Model(&MonitorGroup{}).Where("owner_key = ?", c.GetString(ownerContextKey))
+}
+
+// checkGroupParent verifies that parentID is a group the caller owns and
+// that making it the parent of groupID (0 for a new group) does not close a
+// loop in the hierarchy.
+func checkGroupParent(c *gin.Context, db *gorm.DB, groupID, parentID uint) error {
+	seen := map[uint]bool{}
+	for current := parentID; current != 0; {
+		if current == groupID || seen[current] {
+			return errGroupCycle
+		}
+		seen[current] = true
+		var parent MonitorGroup
+		if err := ownedGroups(c, db).First(&parent, current).Error; err != nil {
+			if current == parentID {
+				return errors.New("parent group not found")
+			}
+			return err
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		current = *parent.ParentID
+	}
+	return nil
+}
+
+// checkGroupMembers verifies that every ID names a monitor the caller owns.
+func checkGroupMembers(c *gin.Context, db *gorm.DB, ids []uint) ([]uint, error) {
+	unique := make([]uint, 0, len(ids))
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+	var count int64
+	if err := ownedMonitors(c, db).Where("id IN ?", unique).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if int(count) != len(unique) {
+		return nil, errors.New("monitor_ids references an unknown monitor")
+	}
+	return unique, nil
+}
+
+func replaceGroupMembers(tx *gorm.DB, groupID uint, monitorIDs []uint) error {
+	if err := tx.Where("group_id = ?", groupID).Delete(&MonitorGroupMember{}).Error; err != nil {
+		return err
+	}
+	if len(monitorIDs) == 0 {
+		return nil
+	}
+	members := make([]MonitorGroupMember, len(monitorIDs))
+	for i, id := range monitorIDs {
+		members[i] = MonitorGroupMember{GroupID: groupID, MonitorID: id}
+	}
+	return tx.Create(&members).Error
+}
+
+// loadGroupMembers fills MonitorIDs on each group.
+func loadGroupMembers(db *gorm.DB, groups []MonitorGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(groups))
+	index := make(map[uint]*MonitorGroup, len(groups))
+	for i := range groups {
+		ids[i] = groups[i].ID
+		groups[i].MonitorIDs = []uint{}
+		index[groups[i].ID] = &groups[i]
+	}
+	var members []MonitorGroupMember
+	if err := db.Where("group_id IN ?", ids).Order("monitor_id asc").Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		group := index[member.GroupID]
+		group.MonitorIDs = append(group.MonitorIDs, member.MonitorID)
+	}
+	return nil
+}
+
+// rollupGroup computes the status of a group from its member monitors and
+// child groups, recursively. visiting guards against cycles that slipped
+// into the table; a group already on the current path is skipped.
+func rollupGroup(group *MonitorGroup, children map[uint][]*MonitorGroup, monitors map[uint]Monitor, visiting map[uint]bool) groupStatus {
+	visiting[group.ID] = true
+	defer delete(visiting, group.ID)
+
+	node := groupStatus{ID: group.ID, Name: group.Name, Monitors: []groupMonitorStatus{}, Groups: []groupStatus{}}
+	var statuses []string
+	for _, id := range group.MonitorIDs {
+		monitor, ok := monitors[id]
+		if !ok {
+			continue
+		}
+		status := strings.ToUpper(monitor.Status)
+		node.Monitors = append(node.Monitors, groupMonitorStatus{ID: monitor.ID, Name: monitor.Name, Status: status, Paused: !monitor.Enabled})
+		if !monitor.Enabled {
+			continue
+		}
+		statuses = append(statuses, status)
+		node.TotalMonitors++
+		if status == statusHealthy {
+			node.HealthyMonitors++
+		}
+	}
+	for _, child := range children[group.ID] {
+		if visiting[child.ID] {
+			continue
+		}
+		childStatus := rollupGroup(child, children, monitors, visiting)
+		node.Groups = append(node.Groups, childStatus)
+		statuses = append(statuses, childStatus.Status)
+		node.TotalMonitors += childStatus.TotalMonitors
+		node.HealthyMonitors += childStatus.HealthyMonitors
+	}
+	node.Status = rollupStatus(statuses)
+	return node
+}
+
+func registerGroupRoutes(router *gin.Engine, db *gorm.DB, keys apiKeys) {
+	router.GET("/group", authorize(keys, true), func(c *gin.Context) {
+		var groups []MonitorGroup
+		if err := ownedGroups(c, db).Order("id asc").Find(&groups).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch groups"})
+			return
+		}
+		if err := loadGroupMembers(db, groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch groups"})
+			return
+		}
+		c.JSON(http.StatusOK, groups)
+	})
+
+	router.POST("/group", authorize(keys, false), func(c *gin.Context) {
+		var req groupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+		if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Name is required"})
+			return
+		}
+
+		group := MonitorGroup{
+			Name:     strings.TrimSpace(*req.Name),
+			OwnerKey: c.GetString(ownerContextKey),
+		}
+		if req.ParentID != nil && *req.ParentID != 0 {
+			if err := checkGroupParent(c, db, 0, *req.ParentID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			parentID := *req.ParentID
+			group.ParentID = &parentID
+		}
+		var monitorIDs []uint
+		if req.MonitorIDs != nil {
+			ids, err := checkGroupMembers(c, db, *req.MonitorIDs)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitorIDs = ids
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&group).Error; err != nil {
+				return err
+			}
+			return replaceGroupMembers(tx, group.ID, monitorIDs)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create group"})
+			return
+		}
+		group.MonitorIDs = append([]uint{}, monitorIDs...)
+		c.JSON(http.StatusCreated, group)
+	})
+
+	router.PUT("/group/:id", authorize(keys, false), func(c *gin.Context) {
+		var req groupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+
+		var group MonitorGroup
+		if err := ownedGroups(c, db).First(&group, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group not found"})
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Name cannot be empty"})
+				return
+			}
+			group.Name = name
+		}
+		if req.ParentID != nil {
+			if *req.ParentID == 0 {
+				group.ParentID = nil
+			} else {
+				if err := checkGroupParent(c, db, group.ID, *req.ParentID); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+					return
+				}
+				parentID := *req.ParentID
+				group.ParentID = &parentID
+			}
+		}
+		var monitorIDs []uint
+		if req.MonitorIDs != nil {
+			ids, err := checkGroupMembers(c, db, *req.MonitorIDs)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitorIDs = ids
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&group).Error; err != nil {
+				return err
+			}
+			if req.MonitorIDs == nil {
+				return nil
+			}
+			return replaceGroupMembers(tx, group.ID, monitorIDs)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update group"})
+			return
+		}
+		groups := []MonitorGroup{group}
+		if err := loadGroupMembers(db, groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch group"})
+			return
+		}
+		c.JSON(http.StatusOK, groups[0])
+	})
+
+	router.DELETE("/group/:id", authorize(keys, false), func(c *gin.Context) {
+		var group MonitorGroup
+		if err := ownedGroups(c, db).First(&group, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group not found"})
+			return
+		}
+		// Child groups move up to the deleted group's parent.
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&MonitorGroup{}).Where("parent_id = ?", group.ID).Update("parent_id", group.ParentID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("group_id = ?", group.ID).Delete(&MonitorGroupMember{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&group).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete group"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Group deleted"})
+	})
+
+	router.GET("/group/:id/status", authorize(keys, true), func(c *gin.Context) {
+		var root MonitorGroup
+		if err := ownedGroups(c, db).First(&root, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group not found"})
+			return
+		}
+
+		var groups []MonitorGroup
+		if err := ownedGroups(c, db).Find(&groups).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch group status"})
+			return
+		}
+		if err := loadGroupMembers(db, groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch group status"})
+			return
+		}
+		var monitors []Monitor
+		if err := ownedMonitors(c, db).Find(&monitors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch group status"})
+			return
+		}
+
+		byID := make(map[uint]Monitor, len(monitors))
+		for _, monitor := range monitors {
+			byID[monitor.ID] = monitor
+		}
+		children := map[uint][]*MonitorGroup{}
+		var start *MonitorGroup
+		for i := range groups {
+			if groups[i].ID == root.ID {
+				start = &groups[i]
+			}
+			if groups[i].ParentID != nil {
+				children[*groups[i].ParentID] = append(children[*groups[i].ParentID], &groups[i])
+			}
+		}
+		if start == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rollupGroup(start, children, byID, map[uint]bool{}))
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxRequestHeaders bounds the headers a monitor may send.
+const maxRequestHeaders = 50
+
+// managedHeaders are set by the HTTP client from the request itself and
+// cannot be overridden per monitor.
+var managedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Te":                true,
+	"Trailer":           true,
+}
+
+// validateRequestHeaders checks the names and values of a monitor's headers.
+func validateRequestHeaders(headers map[string]string) error {
+	if len(headers) > maxRequestHeaders {
+		return fmt.Errorf("headers cannot list more than %d headers", maxRequestHeaders)
+	}
+	for name, value := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if managedHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s cannot be set", http.CanonicalHeaderKey(name))
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("invalid value for header %s", name)
+		}
+	}
+	return nil
+}
+
+// applyRequestHeaders sets the monitor's own headers on req, replacing any
+// the client profile or request body set. Host overrides the virtual host
+// sent to the server without changing where the request connects.
+func applyRequestHeaders(headers map[string]string, req *http.Request) {
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+	defaultUptimeWindow = 24 * time.Hour
+)
+
+// CheckResult is one completed check of a monitor.
+type CheckResult struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MonitorID      uint      `json:"monitor_id" gorm:"index:idx_check_results_monitor_time,priority:1;not null"`
+	CheckedAt      time.Time `json:"checked_at" gorm:"index:idx_check_results_monitor_time,priority:2"`
+	Status         string    `json:"status"`
+	ResponseCode   int       `json:"response_code"`
+	ResponseTimeMs int       `json:"response_time_ms"`
+	Error          string    `json:"error"`
+	ErrorCategory  string    `json:"error_category"`
+	// FailedStep names the step a transaction monitor failed at.
+	FailedStep string `json:"failed_step,omitempty"`
+	// Endpoint is primary or fallback for monitors with a fallback_url.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Samples is how many checks a downsampled point stands for.
+	Samples int `json:"samples,omitempty" gorm:"-"`
+}
+
+// checkResultFromUpdate builds the history row for a check from the column
+// updates it produced. Updates without a status (cancelled checks) are not
+// results and return false.
+func checkResultFromUpdate(monitorID uint, update map[string]interface{}) (CheckResult, bool) {
+	status, ok := update["status"].(string)
+	if !ok {
+		return CheckResult{}, false
+	}
+	result := CheckResult{MonitorID: monitorID, Status: status}
+	result.CheckedAt, _ = update["last_check"].(time.Time)
+	result.ResponseCode, _ = update["last_response_code"].(int)
+	result.ResponseTimeMs, _ = update["last_response_time_ms"].(int)
+	result.Error, _ = update["last_error"].(string)
+	result.ErrorCategory, _ = update["last_error_category"].(string)
+	result.FailedStep, _ = update["last_failed_step"].(string)
+	result.Endpoint, _ = update["last_endpoint"].(string)
+	return result, true
+}
+
+func registerHistoryRoutes(router *gin.Engine, db *gorm.DB, keys apiKeys) {
+	router.GET("/monitor/:id/history", authorize(keys, true), func(c *gin.Context) {
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+			return
+		}
+
+		query := db.Where("monitor_id = ?", monitor.ID).Order("checked_at desc, id desc")
+		// A window returns every check in it unless a limit is also given.
+		limit := defaultHistoryLimit
+		if raw := c.Query("window"); raw != "" {
+			window, err := time.ParseDuration(raw)
+			if err != nil || window <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "window must be a positive duration such as 24h"})
+				return
+			}
+			query = query.Where("checked_at > ?", time.Now().Add(-window))
+			limit = -1
+		}
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed <= 0 || parsed > maxHistoryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "limit must be between 1 and 1000"})
+				return
+			}
+			limit = parsed
+		}
+		maxPoints := 0
+		if raw := c.Query("max_points"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed <= 0 || parsed > maxHistoryPoints {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "max_points must be between 1 and 1000"})
+				return
+			}
+			maxPoints = parsed
+		}
+
+		results := []CheckResult{}
+		if err := query.Limit(limit).Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch history"})
+			return
+		}
+		if maxPoints > 0 {
+			reverseResults(results)
+			results = downsampleHistory(results, maxPoints)
+			reverseResults(results)
+		}
+		c.JSON(http.StatusOK, results)
+	})
+
+	router.GET("/monitor/:id/uptime", authorize(keys, true), func(c *gin.Context) {
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+			return
+		}
+
+		window := defaultUptimeWindow
+		if raw := c.Query("window"); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "window must be a positive duration such as 24h"})
+				return
+			}
+			window = parsed
+		}
+		to := time.Now()
+		from := to.Add(-window)
+
+		var counts struct {
+			Total   int64
+			Healthy int64
+		}
+		err := db.Model(&CheckResult{}).
+			Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS healthy", statusHealthy).
+			Where("monitor_id = ? AND checked_at > ? AND checked_at <= ?", monitor.ID, from, to).
+			Scan(&counts).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to compute uptime"})
+			return
+		}
+
+		// Without checks in the window uptime is unknown rather than zero.
+		var uptime *float64
+		if counts.Total > 0 {
+			value := float64(counts.Healthy) / float64(counts.Total)
+			uptime = &value
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"monitor_id":     monitor.ID,
+			"window":         window.String(),
+			"from":           from,
+			"to":             to,
+			"uptime":         uptime,
+			"total_checks":   counts.Total,
+			"healthy_checks": counts.Healthy,
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxIngestBatchSize bounds how many results one ingest request may carry.
+const maxIngestBatchSize = 1000
+
+// ingestResult is one check result reported by a remote probe. Field names
+// are short because the batch is usually sent as MessagePack.
+type ingestResult struct {
+	MonitorID      uint   `json:"id"`
+	Status         string `json:"s"`
+	ResponseCode   int    `json:"c"`
+	ResponseTimeMs int    `json:"t"`
+	CheckedAt      int64  `json:"at"`
+	Error          string `json:"e"`
+	ErrorCategory  string `json:"ec"`
+}
+
+type ingestBatch struct {
+	Results []ingestResult `json:"r"`
+}
+
+// registerIngestRoutes exposes the bulk result endpoint used by remote check
+// workers. Batches are accepted as MessagePack or JSON based on Content-Type.
+func registerIngestRoutes(router *gin.Engine, db *gorm.DB, checker *monitorChecker, keys apiKeys) {
+	router.POST("/ingest/results", authorize(keys, false), func(c *gin.Context) {
+		var batch ingestBatch
+		if err := c.ShouldBind(&batch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid batch"})
+			return
+		}
+		if len(batch.Results) == 0 || len(batch.Results) > maxIngestBatchSize {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Batch must contain between 1 and 1000 results"})
+			return
+		}
+
+		now := time.Now()
+		ids := make([]uint, 0, len(batch.Results))
+		for i := range batch.Results {
+			result := &batch.Results[i]
+			result.Status = strings.ToUpper(strings.TrimSpace(result.Status))
+			if !validIngestStatus(result.Status) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid status in result", "index": i})
+				return
+			}
+			if result.CheckedAt <= 0 || time.UnixMilli(result.CheckedAt).After(now.Add(time.Minute)) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid checked-at timestamp in result", "index": i})
+				return
+			}
+			if result.ResponseCode < 0 || result.ResponseTimeMs < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid metrics in result", "index": i})
+				return
+			}
+			ids = append(ids, result.MonitorID)
+		}
+
+		var monitors []Monitor
+		if err := ownedMonitors(c, db).Where("id IN ?", ids).Find(&monitors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load monitors"})
+			return
+		}
+		byID := make(map[uint]*Monitor, len(monitors))
+		for i := range monitors {
+			byID[monitors[i].ID] = &monitors[i]
+		}
+		for i, result := range batch.Results {
+			if byID[result.MonitorID] == nil {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found", "index": i})
+				return
+			}
+		}
+
+		// Apply oldest first so each monitor's smoothing sees results in order.
+		sort.SliceStable(batch.Results, func(i, j int) bool {
+			return batch.Results[i].CheckedAt < batch.Results[j].CheckedAt
+		})
+		accepted, skipped := 0, 0
+		var published []publishedResult
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, result := range batch.Results {
+				monitor := byID[result.MonitorID]
+				checkedAt := time.UnixMilli(result.CheckedAt)
+				if !monitor.Enabled || !checkedAt.After(monitor.LastCheck) {
+					skipped++
+					continue
+				}
+				update := map[string]interface{}{
+					"status":                result.Status,
+					"last_check":            checkedAt,
+					"last_response_code":    result.ResponseCode,
+					"last_response_time_ms": result.ResponseTimeMs,
+					"last_error":            result.Error,
+					"last_error_category":   result.ErrorCategory,
+				}
+				applyErrorRate(monitor, update)
+				checker.detectLatencyAnomaly(monitor, update)
+				checkJitter(monitor, update)
+				checker.smoothResponseTime(monitor, update)
+				countFailures(monitor, update)
+				confirmRecovery(monitor, update)
+				reported, severity, alert := checker.alertTransition(monitor, update)
+				if err := tx.Model(monitor).UpdateColumns(update).Error; err != nil {
+					return err
+				}
+				if history, ok := checkResultFromUpdate(monitor.ID, update); ok {
+					if err := tx.Create(&history).Error; err != nil {
+						return err
+					}
+				}
+				accepted++
+				published = append(published, publishedResult{monitor: *monitor, previous: reported, severity: severity, alert: alert, update: update})
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to apply batch"})
+			return
+		}
+		for _, result := range published {
+			checker.publisher.publish(&result.monitor, result.update)
+			if result.alert {
+				checker.notify(&result.monitor, result.previous, result.severity, result.update)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"accepted": accepted, "skipped": skipped})
+	})
+}
+
+// publishedResult is an applied result waiting for the transaction to commit
+// before it is streamed and alerted on.
+type publishedResult struct {
+	monitor Monitor
+	// previous is the status last reported, for a result that alert says is
+	// reported.
+	previous string
+	severity string
+	alert    bool
+	update   map[string]interface{}
+}
+
+func validIngestStatus(status string) bool {
+	switch status {
+	case statusHealthy, statusDegraded, statusUnhealthy, statusUnknown:
+		return true
+	}
+	return false
+}
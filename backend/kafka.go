@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// checkEvent is the JSON message published for every check result.
+type checkEvent struct {
+	MonitorID      uint      `json:"monitor_id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Status         string    `json:"status"`
+	CheckedAt      time.Time `json:"checked_at"`
+	ResponseCode   int       `json:"response_code"`
+	ResponseTimeMs int       `json:"response_time_ms"`
+	Error          string    `json:"error,omitempty"`
+	ErrorCategory  string    `json:"error_category,omitempty"`
+}
+
+// resultPublisher streams check results to Kafka without ever blocking the
+// checker: events go through a bounded buffer and are dropped (and counted)
+// when it is full.
+type resultPublisher struct {
+	writer  *kafka.Writer
+	events  chan kafka.Message
+	dropped atomic.Int64
+}
+
+func newResultPublisher(brokers []string, topic string, bufferSize int) *resultPublisher {
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	return &resultPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 100 * time.Millisecond,
+			RequiredAcks: kafka.RequireOne,
+		},
+		events: make(chan kafka.Message, bufferSize),
+	}
+}
+
+// start drains the buffer into Kafka until ctx is cancelled.
+func (p *resultPublisher) start(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	go func() {
+		defer p.writer.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-p.events:
+				batch := []kafka.Message{msg}
+			drain:
+				for len(batch) < cap(p.events) {
+					select {
+					case next := <-p.events:
+						batch = append(batch, next)
+					default:
+						break drain
+					}
+				}
+				if err := p.writer.WriteMessages(ctx, batch...); err != nil {
+					slog.Error("kafka publish failed", "results", len(batch), "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// publish queues the result of one check. Monitors are used as message keys
+// so each monitor's results stay ordered within a partition.
+func (p *resultPublisher) publish(monitor *Monitor, update map[string]interface{}) {
+	if p == nil {
+		return
+	}
+	event := checkEvent{MonitorID: monitor.ID, Name: monitor.Name, Type: monitor.Type}
+	event.Status, _ = update["status"].(string)
+	if event.Status == "" {
+		event.Status = monitor.Status
+	}
+	event.CheckedAt, _ = update["last_check"].(time.Time)
+	event.ResponseCode, _ = update["last_response_code"].(int)
+	event.ResponseTimeMs, _ = update["last_response_time_ms"].(int)
+	event.Error, _ = update["last_error"].(string)
+	event.ErrorCategory, _ = update["last_error_category"].(string)
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("check event encoding failed", "monitor_id", monitor.ID, "error", err)
+		return
+	}
+	msg := kafka.Message{Key: []byte(strconv.FormatUint(uint64(monitor.ID), 10)), Value: value}
+	select {
+	case p.events <- msg:
+	default:
+		dropped := p.dropped.Add(1)
+		slog.Warn("kafka buffer full, dropped check result", "monitor_id", monitor.ID, "dropped", dropped)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// scheduleLagKey carries the scheduling lag of a check in its context.
+type scheduleLagKey struct{}
+
+// withScheduleLag records that the check run with ctx started lag after it
+// was due.
+func withScheduleLag(ctx context.Context, lag time.Duration) context.Context {
+	return context.WithValue(ctx, scheduleLagKey{}, lag)
+}
+
+// scheduleLag returns the lag recorded by withScheduleLag. Checks triggered
+// by hand or by a resume have none.
+func scheduleLag(ctx context.Context) (time.Duration, bool) {
+	lag, ok := ctx.Value(scheduleLagKey{}).(time.Duration)
+	return lag, ok
+}
+
+// scheduleLagAlarm follows the worst scheduling lag of each batch of checks.
+// With a threshold it reports when the lag first exceeds it and when a later
+// batch is back under it. A nil alarm does nothing.
+type scheduleLagAlarm struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	worst   time.Duration
+	lagging bool
+}
+
+func newScheduleLagAlarm(threshold time.Duration) *scheduleLagAlarm {
+	return &scheduleLagAlarm{threshold: threshold}
+}
+
+// observe records the worst lag of a batch and reports whether the alarm
+// changed state, and so needs a notification.
+func (a *scheduleLagAlarm) observe(worst time.Duration) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.worst = worst
+	if a.threshold <= 0 {
+		return false
+	}
+	lagging := worst > a.threshold
+	changed := lagging != a.lagging
+	a.lagging = lagging
+	return changed
+}
+
+// state returns the worst lag of the latest batch and whether it is over the
+// threshold.
+func (a *scheduleLagAlarm) state() (time.Duration, bool) {
+	if a == nil {
+		return 0, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.worst, a.lagging
+}
+
+// notifyScheduleLag logs a change of the lag alarm and sends it to Slack and
+// email when they are configured.
+func (mc *monitorChecker) notifyScheduleLag(worst time.Duration, lagging bool) {
+	threshold := mc.lagAlarm.threshold
+	title := fmt.Sprintf("Checks are running %s late", worst.Round(time.Millisecond))
+	body := fmt.Sprintf("Scheduled checks started up to %s after they were due, above the %s threshold. The checker may be overloaded.\n", worst.Round(time.Millisecond), threshold)
+	color := slackColorWarning
+	if !lagging {
+		title = "Checks are on schedule again"
+		body = fmt.Sprintf("Scheduled checks started at most %s after they were due, within the %s threshold.\n", worst.Round(time.Millisecond), threshold)
+		color = slackColorGood
+	}
+	slog.Warn("scheduling lag", "lagging", lagging, "worst_lag_ms", worst.Milliseconds(), "threshold_ms", threshold.Milliseconds())
+	if message, ok := mc.slack.notice(color, title, body); ok {
+		mc.spawn(func() {
+			if err := mc.slack.deliver(message); err != nil {
+				slog.Error("slack scheduling lag message failed", "error", err)
+			}
+		})
+	}
+	if mc.email != nil {
+		mc.spawn(func() {
+			if err := mc.email.deliver(email{subject: "[UselessMonitor] " + title, body: body}); err != nil {
+				slog.Error("scheduling lag email failed", "error", err)
+			}
+		})
+	}
+}
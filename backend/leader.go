@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const leaderLeaseID = 1
+
+// LeaderLease is a single-row table recording which instance currently owns
+// the right to run scheduled checks.
+type LeaderLease struct {
+	ID        uint      `gorm:"primaryKey"`
+	Holder    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// leaderElector competes for the lease so that only one of several instances
+// sharing a database runs the checker. A nil elector is always the leader.
+type leaderElector struct {
+	db       *gorm.DB
+	id       string
+	lease    time.Duration
+	isLeader atomic.Bool
+}
+
+func newLeaderElector(db *gorm.DB, id string, lease time.Duration) *leaderElector {
+	if id == "" {
+		id = defaultInstanceID()
+	}
+	if lease <= 0 {
+		lease = 15 * time.Second
+	}
+	return &leaderElector{db: db, id: id, lease: lease}
+}
+
+// leader reports whether this instance should run scheduled checks.
+func (le *leaderElector) leader() bool {
+	if le == nil {
+		return true
+	}
+	return le.isLeader.Load()
+}
+
+// role describes the instance for /healthz.
+func (le *leaderElector) role() string {
+	if le.leader() {
+		return "leader"
+	}
+	return "standby"
+}
+
+// start acquires the lease immediately and then renews it at a third of the
+// lease duration until ctx is cancelled.
+func (le *leaderElector) start(ctx context.Context) {
+	le.tryAcquire()
+	go func() {
+		ticker := time.NewTicker(le.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				le.tryAcquire()
+			case <-ctx.Done():
+				le.release()
+				return
+			}
+		}
+	}()
+}
+
+func (le *leaderElector) tryAcquire() {
+	if err := le.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&LeaderLease{ID: leaderLeaseID}).Error; err != nil {
+		slog.Error("leader lease init failed", "error", err)
+	}
+
+	now := time.Now()
+	result := le.db.Model(&LeaderLease{}).
+		Where("id = ? AND (holder = ? OR expires_at < ?)", leaderLeaseID, le.id, now).
+		Updates(map[string]interface{}{"holder": le.id, "expires_at": now.Add(le.lease)})
+	acquired := result.Error == nil && result.RowsAffected == 1
+	if result.Error != nil {
+		slog.Error("leader lease renewal failed", "error", result.Error)
+	}
+
+	if was := le.isLeader.Swap(acquired); was != acquired {
+		slog.Info("leadership changed", "instance_id", le.id, "role", le.role())
+	}
+}
+
+// release gives up the lease so a standby can take over without waiting for
+// it to expire.
+func (le *leaderElector) release() {
+	if !le.isLeader.Swap(false) {
+		return
+	}
+	err := le.db.Model(&LeaderLease{}).
+		Where("id = ? AND holder = ?", leaderLeaseID, le.id).
+		Update("expires_at", time.Time{}).Error
+	if err != nil {
+		slog.Error("leader lease release failed", "error", err)
+	}
+}
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
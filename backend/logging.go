@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseLogLevel reads LOG_LEVEL: debug, info, warn, or error, defaulting to
+// info.
+func parseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("%q is not one of debug, info, warn, error", raw)
+}
+
+// newLogger returns a JSON logger writing to out that drops records below
+// level and scrubs secret values from the message and every string or error
+// attribute. Made the default, it also receives the log package's output,
+// at info.
+func newLogger(out io.Writer, level slog.Level, secrets *secretStore) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			switch value := attr.Value.Any().(type) {
+			case string:
+				attr.Value = slog.StringValue(secrets.redact(value))
+			case error:
+				attr.Value = slog.StringValue(secrets.redact(value.Error()))
+			}
+			return attr
+		},
+	}))
+}
+
+// requestLogger logs every API request at info. Only the path is logged, so
+// keys passed in query strings stay out of the logs.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		slog.Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
@@ -1,45 +1,322 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 // Monitor represents a monitored target and its latest state.
 type Monitor struct {
-	ID                 uint      `json:"id" gorm:"primaryKey"`
-	Name               string    `json:"name" gorm:"not null"`
-	Type               string    `json:"type" gorm:"not null"`
-	URL                string    `json:"url" gorm:"not null"`
-	Status             string    `json:"status" gorm:"not null;default:UNKNOWN"`
-	LastCheck          time.Time `json:"last_check"`
-	LastResponseCode   int       `json:"last_response_code"`
-	LastResponseTimeMs int       `json:"last_response_time_ms"`
+	ID                          uint             `json:"id" gorm:"primaryKey"`
+	Name                        string           `json:"name" gorm:"not null"`
+	Type                        string           `json:"type" gorm:"not null"`
+	URL                         string           `json:"url" gorm:"not null"`
+	Status                      string           `json:"status" gorm:"not null;default:UNKNOWN"`
+	LastCheck                   time.Time        `json:"last_check"`
+	LastResponseCode            int              `json:"last_response_code"`
+	LastResponseTimeMs          int              `json:"last_response_time_ms"`
+	CertPinned                  bool             `json:"cert_pinned"`
+	CertFingerprint             string           `json:"cert_fingerprint"`
+	LastCertFingerprint         string           `json:"last_cert_fingerprint"`
+	ChangeThresholdPercent      float64          `json:"change_threshold_percent"`
+	LastChangePercent           float64          `json:"last_change_percent"`
+	LastBody                    string           `json:"-"`
+	OwnerKey                    string           `json:"-" gorm:"index"`
+	FeedMaxAgeSeconds           int              `json:"feed_max_age_seconds"`
+	LatestEntryAt               time.Time        `json:"latest_entry_at"`
+	FailureMessage              string           `json:"failure_message"`
+	HealthBoolPath              string           `json:"health_bool_path"`
+	LastError                   string           `json:"last_error"`
+	LastErrorCategory           string           `json:"last_error_category"`
+	TemplateID                  *uint            `json:"template_id" gorm:"index"`
+	TemplateVars                stringMap        `json:"template_vars"`
+	HeaderAssertions            stringMap        `json:"header_assertions"`
+	SNIHostname                 string           `json:"sni_hostname"`
+	DBQuery                     string           `json:"db_query"`
+	XPath                       string           `json:"xpath"`
+	XPathExpected               string           `json:"xpath_expected"`
+	CheckAllIPs                 bool             `json:"check_all_ips"`
+	IPResults                   ipResultList     `json:"ip_results"`
+	SmoothedResponseTimeMs      float64          `json:"smoothed_response_time_ms"`
+	DebugLogging                bool             `json:"debug_logging"`
+	Priority                    int              `json:"priority"`
+	UDPPayload                  string           `json:"udp_payload"`
+	AnomalyZScoreThreshold      float64          `json:"anomaly_z_score_threshold"`
+	ResponseTimeStdDevMs        float64          `json:"response_time_std_dev_ms"`
+	ResponseTimeSamples         int              `json:"response_time_samples"`
+	LastZScore                  float64          `json:"last_z_score"`
+	RegionHeader                string           `json:"region_header"`
+	ExpectedRegions             stringList       `json:"expected_regions"`
+	LastRegion                  string           `json:"last_region"`
+	RecoveryConfirmationSeconds int              `json:"recovery_confirmation_seconds"`
+	RecoveringSince             *time.Time       `json:"recovering_since"`
+	UserAgents                  stringList       `json:"user_agents"`
+	UserAgentRotation           int              `json:"-"`
+	LastUserAgent               string           `json:"last_user_agent"`
+	ClientProfile               string           `json:"client_profile"`
+	Public                      bool             `json:"public"`
+	IntervalSeconds             int              `json:"interval_seconds"`
+	LastContentLength           int              `json:"last_content_length"`
+	MaxJitterMs                 float64          `json:"max_jitter_ms"`
+	TimeoutSeconds              int              `json:"timeout_seconds"`
+	DegradedThresholdMs         int              `json:"degraded_threshold_ms"`
+	ErrorRateWindow             int              `json:"error_rate_window"`
+	ErrorRateDegradedPercent    float64          `json:"error_rate_degraded_percent"`
+	ErrorRateUnhealthyPercent   float64          `json:"error_rate_unhealthy_percent"`
+	RecentOutcomes              string           `json:"-"`
+	LastErrorRate               float64          `json:"last_error_rate"`
+	RetryCount                  *int             `json:"retry_count"`
+	LastAttempts                int              `json:"last_attempts"`
+	BodyStripPrefix             string           `json:"body_strip_prefix"`
+	BodyStripSuffix             string           `json:"body_strip_suffix"`
+	BodyReplacePattern          string           `json:"body_replace_pattern"`
+	BodyReplacement             string           `json:"body_replacement"`
+	ConsecutiveFailures         int              `json:"consecutive_failures"`
+	CreatedAt                   time.Time        `json:"created_at"`
+	UpdatedAt                   time.Time        `json:"updated_at"`
+	PagerDutyRoutingKey         string           `json:"-"`
+	LastBytesSent               int              `json:"last_bytes_sent"`
+	LastBytesReceived           int              `json:"last_bytes_received"`
+	TotalBytesSent              int64            `json:"total_bytes_sent"`
+	TotalBytesReceived          int64            `json:"total_bytes_received"`
+	BandwidthResetAt            *time.Time       `json:"bandwidth_reset_at"`
+	Enabled                     bool             `json:"enabled" gorm:"default:true"`
+	ExpectClosed                bool             `json:"expect_closed"`
+	CertExpiryDays              int              `json:"cert_expiry_days" gorm:"default:-1"`
+	CertExpiryThresholdDays     *int             `json:"cert_expiry_threshold_days"`
+	ExpectedKeyword             string           `json:"expected_keyword"`
+	ColdTimeoutSeconds          int              `json:"cold_timeout_seconds"`
+	ColdAfterSeconds            int              `json:"cold_after_seconds"`
+	ExpectedStatusCodes         string           `json:"expected_status_codes"`
+	Method                      string           `json:"method" gorm:"default:GET"`
+	RequestBody                 string           `json:"request_body"`
+	SamplePaths                 stringList       `json:"sample_paths"`
+	PathSampling                string           `json:"path_sampling"`
+	PathRotation                int              `json:"-"`
+	LastPath                    string           `json:"last_path"`
+	PathStatuses                stringMap        `json:"path_statuses"`
+	Headers                     stringMap        `json:"headers"`
+	NotificationSchedule        severitySchedule `json:"notification_schedule"`
+	NotificationTimezone        string           `json:"notification_timezone"`
+	Steps                       transactionSteps `json:"steps"`
+	LastFailedStep              string           `json:"last_failed_step"`
+	FailureThreshold            *int             `json:"failure_threshold"`
+	AlertedStatus               string           `json:"alerted_status"`
+	LastScheduleLagMs           int              `json:"last_schedule_lag_ms"`
+	FallbackURL                 string           `json:"fallback_url"`
+	LastEndpoint                string           `json:"last_endpoint"`
+}
+
+// checkTimeout is how long a single check of the monitor may take.
+func (m *Monitor) checkTimeout() time.Duration {
+	if m.TimeoutSeconds > 0 {
+		return time.Duration(m.TimeoutSeconds) * time.Second
+	}
+	return defaultCheckTimeout
+}
+
+// firstAttemptTimeout is the timeout of a check's first attempt: the longer
+// cold_timeout_seconds when the monitor has not been checked within
+// cold_after_seconds (or ever), so a target that starts lazily gets time to
+// wake up. Retries and warm checks use checkTimeout.
+func (m *Monitor) firstAttemptTimeout() (time.Duration, bool) {
+	timeout := m.checkTimeout()
+	cold := time.Duration(m.ColdTimeoutSeconds) * time.Second
+	if cold <= timeout {
+		return timeout, false
+	}
+	idle := defaultColdAfter
+	if m.ColdAfterSeconds > 0 {
+		idle = time.Duration(m.ColdAfterSeconds) * time.Second
+	}
+	if !m.LastCheck.IsZero() && time.Since(m.LastCheck) < idle {
+		return timeout, false
+	}
+	return cold, true
+}
+
+// isType reports whether the monitor's type matches t, ignoring case.
+func (m *Monitor) isType(t string) bool {
+	return strings.EqualFold(strings.TrimSpace(m.Type), t)
+}
+
+// redactedURL is the monitor's URL as notifications and traces show it, with
+// the password of a database DSN or any other userinfo masked.
+func (m *Monitor) redactedURL() string {
+	parsed, err := url.Parse(m.URL)
+	if err != nil {
+		return m.URL
+	}
+	return parsed.Redacted()
+}
+
+// needsBody reports whether any configured check inspects the response body.
+func (m *Monitor) needsBody() bool {
+	return m.ChangeThresholdPercent > 0 || m.isType(monitorTypeFeed) || m.HealthBoolPath != "" || m.XPath != "" || m.ExpectedKeyword != ""
+}
+
+// nextUserAgent returns the user agent for this check when the monitor
+// rotates through a list, or "" to keep Go's default.
+func (m *Monitor) nextUserAgent() string {
+	if len(m.UserAgents) == 0 {
+		return ""
+	}
+	return m.UserAgents[m.UserAgentRotation%len(m.UserAgents)]
+}
+
+// IdempotencyKey records the monitor created for a client-supplied
+// Idempotency-Key so retried POST /monitor requests can be replayed.
+type IdempotencyKey struct {
+	Key       string    `gorm:"primaryKey"`
+	MonitorID uint      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// PendingCheck is a triggered check that has not finished yet. It is persisted
+// so checks requested right before a restart still run afterwards.
+type PendingCheck struct {
+	ID        uint      `gorm:"primaryKey"`
+	MonitorID uint      `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"index"`
 }
 
 // monitorCreateRequest captures required data for creating a monitor.
 type monitorCreateRequest struct {
-	Name string `json:"name" binding:"required"`
-	Type string `json:"type" binding:"required"`
-	URL  string `json:"url" binding:"required"`
+	Name                        string            `json:"name"`
+	Type                        string            `json:"type" binding:"required"`
+	URL                         string            `json:"url" binding:"required"`
+	CertPinned                  bool              `json:"cert_pinned"`
+	ChangeThresholdPercent      float64           `json:"change_threshold_percent"`
+	FeedMaxAgeSeconds           int               `json:"feed_max_age_seconds"`
+	FailureMessage              string            `json:"failure_message"`
+	HealthBoolPath              string            `json:"health_bool_path"`
+	HeaderAssertions            map[string]string `json:"header_assertions"`
+	SNIHostname                 string            `json:"sni_hostname"`
+	DBQuery                     string            `json:"db_query"`
+	XPath                       string            `json:"xpath"`
+	XPathExpected               string            `json:"xpath_expected"`
+	CheckAllIPs                 bool              `json:"check_all_ips"`
+	DebugLogging                bool              `json:"debug_logging"`
+	Priority                    int               `json:"priority"`
+	UDPPayload                  string            `json:"udp_payload"`
+	AnomalyZScoreThreshold      float64           `json:"anomaly_z_score_threshold"`
+	RegionHeader                string            `json:"region_header"`
+	ExpectedRegions             []string          `json:"expected_regions"`
+	RecoveryConfirmationSeconds int               `json:"recovery_confirmation_seconds"`
+	UserAgents                  []string          `json:"user_agents"`
+	ClientProfile               string            `json:"client_profile"`
+	Public                      bool              `json:"public"`
+	IntervalSeconds             int               `json:"interval_seconds"`
+	MaxJitterMs                 float64           `json:"max_jitter_ms"`
+	TimeoutSeconds              int               `json:"timeout_seconds"`
+	DegradedThresholdMs         int               `json:"degraded_threshold_ms"`
+	ErrorRateWindow             int               `json:"error_rate_window"`
+	ErrorRateDegradedPercent    float64           `json:"error_rate_degraded_percent"`
+	ErrorRateUnhealthyPercent   float64           `json:"error_rate_unhealthy_percent"`
+	RetryCount                  *int              `json:"retry_count"`
+	BodyStripPrefix             string            `json:"body_strip_prefix"`
+	BodyStripSuffix             string            `json:"body_strip_suffix"`
+	BodyReplacePattern          string            `json:"body_replace_pattern"`
+	BodyReplacement             string            `json:"body_replacement"`
+	PagerDutyRoutingKey         string            `json:"pagerduty_routing_key"`
+	ExpectClosed                bool              `json:"expect_closed"`
+	CertExpiryThresholdDays     *int              `json:"cert_expiry_threshold_days"`
+	ExpectedKeyword             string            `json:"expected_keyword"`
+	ColdTimeoutSeconds          int               `json:"cold_timeout_seconds"`
+	ColdAfterSeconds            int               `json:"cold_after_seconds"`
+	ExpectedStatusCodes         string            `json:"expected_status_codes"`
+	Method                      string            `json:"method"`
+	RequestBody                 string            `json:"request_body"`
+	SamplePaths                 []string          `json:"sample_paths"`
+	PathSampling                string            `json:"path_sampling"`
+	Headers                     map[string]string `json:"headers"`
+	NotificationSchedule        []severityWindow  `json:"notification_schedule"`
+	NotificationTimezone        string            `json:"notification_timezone"`
+	Steps                       []transactionStep `json:"steps"`
+	FailureThreshold            *int              `json:"failure_threshold"`
+	FallbackURL                 string            `json:"fallback_url"`
 }
 
 // monitorUpdateRequest captures fields that can be updated for a monitor.
 type monitorUpdateRequest struct {
-	Name *string `json:"name"`
-	Type *string `json:"type"`
-	URL  *string `json:"url"`
+	Name                        *string            `json:"name"`
+	Type                        *string            `json:"type"`
+	URL                         *string            `json:"url"`
+	CertPinned                  *bool              `json:"cert_pinned"`
+	ChangeThresholdPercent      *float64           `json:"change_threshold_percent"`
+	FeedMaxAgeSeconds           *int               `json:"feed_max_age_seconds"`
+	FailureMessage              *string            `json:"failure_message"`
+	HealthBoolPath              *string            `json:"health_bool_path"`
+	HeaderAssertions            *map[string]string `json:"header_assertions"`
+	SNIHostname                 *string            `json:"sni_hostname"`
+	DBQuery                     *string            `json:"db_query"`
+	XPath                       *string            `json:"xpath"`
+	XPathExpected               *string            `json:"xpath_expected"`
+	CheckAllIPs                 *bool              `json:"check_all_ips"`
+	DebugLogging                *bool              `json:"debug_logging"`
+	Priority                    *int               `json:"priority"`
+	UDPPayload                  *string            `json:"udp_payload"`
+	AnomalyZScoreThreshold      *float64           `json:"anomaly_z_score_threshold"`
+	RegionHeader                *string            `json:"region_header"`
+	ExpectedRegions             *[]string          `json:"expected_regions"`
+	RecoveryConfirmationSeconds *int               `json:"recovery_confirmation_seconds"`
+	UserAgents                  *[]string          `json:"user_agents"`
+	ClientProfile               *string            `json:"client_profile"`
+	Public                      *bool              `json:"public"`
+	IntervalSeconds             *int               `json:"interval_seconds"`
+	MaxJitterMs                 *float64           `json:"max_jitter_ms"`
+	TimeoutSeconds              *int               `json:"timeout_seconds"`
+	DegradedThresholdMs         *int               `json:"degraded_threshold_ms"`
+	ErrorRateWindow             *int               `json:"error_rate_window"`
+	ErrorRateDegradedPercent    *float64           `json:"error_rate_degraded_percent"`
+	ErrorRateUnhealthyPercent   *float64           `json:"error_rate_unhealthy_percent"`
+	RetryCount                  *int               `json:"retry_count"`
+	BodyStripPrefix             *string            `json:"body_strip_prefix"`
+	BodyStripSuffix             *string            `json:"body_strip_suffix"`
+	BodyReplacePattern          *string            `json:"body_replace_pattern"`
+	BodyReplacement             *string            `json:"body_replacement"`
+	PagerDutyRoutingKey         *string            `json:"pagerduty_routing_key"`
+	ExpectClosed                *bool              `json:"expect_closed"`
+	CertExpiryThresholdDays     *int               `json:"cert_expiry_threshold_days"`
+	ExpectedKeyword             *string            `json:"expected_keyword"`
+	ColdTimeoutSeconds          *int               `json:"cold_timeout_seconds"`
+	ColdAfterSeconds            *int               `json:"cold_after_seconds"`
+	ExpectedStatusCodes         *string            `json:"expected_status_codes"`
+	Method                      *string            `json:"method"`
+	RequestBody                 *string            `json:"request_body"`
+	SamplePaths                 *[]string          `json:"sample_paths"`
+	PathSampling                *string            `json:"path_sampling"`
+	Headers                     *map[string]string `json:"headers"`
+	NotificationSchedule        *[]severityWindow  `json:"notification_schedule"`
+	NotificationTimezone        *string            `json:"notification_timezone"`
+	Steps                       *[]transactionStep `json:"steps"`
+	FailureThreshold            *int               `json:"failure_threshold"`
+	FallbackURL                 *string            `json:"fallback_url"`
 }
 
 const (
@@ -49,29 +326,154 @@ const (
 	statusUnknown   = "UNKNOWN"
 )
 
+// Failure categories recorded in last_error_category when a request fails.
+const (
+	errorCategoryTimeout = "timeout"
+	errorCategoryDNS     = "dns"
+	errorCategoryRefused = "connection_refused"
+	errorCategoryTLS     = "tls"
+	errorCategoryOther   = "connection"
+	// errorCategoryCanceled marks a check abandoned because its caller went
+	// away; it says nothing about the target's health.
+	errorCategoryCanceled = "canceled"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// defaultResponseTimeSmoothing is the EWMA factor used when
+// RESPONSE_TIME_SMOOTHING is unset or out of range.
+const defaultResponseTimeSmoothing = 0.3
+
+// defaultCheckTimeout applies to monitors without their own timeout_seconds.
+const defaultCheckTimeout = 10 * time.Second
+
+// defaultColdAfter is how long a monitor with cold_timeout_seconds must go
+// unchecked before its next check counts as cold.
+const defaultColdAfter = 15 * time.Minute
+
+// defaultMaxCheckDuration is the hard deadline of a whole check when
+// MAX_CHECK_DURATION is unset. It leaves room for every attempt with the
+// default timeout and retries: 3 × 10s plus 1.5s of backoff.
+const defaultMaxCheckDuration = 35 * time.Second
+
+// shutdownGrace is how long shutdown waits beyond the check deadline, leaving
+// a check that ran into its deadline time to save its result.
+const shutdownGrace = 5 * time.Second
+
+// defaultMaxConcurrentChecks bounds scheduled checks in flight when
+// MAX_CONCURRENT_CHECKS is unset.
+const defaultMaxConcurrentChecks = 20
+
+// schedulerTick is how often the checker looks for monitors that are due.
+const schedulerTick = time.Second
+
+// maxBodyBytes bounds how much of a response body is read for content checks.
+const maxBodyBytes = 1 << 20
+
 type monitorChecker struct {
-	db     *gorm.DB
-	client *http.Client
+	db       *gorm.DB
+	client   *http.Client
+	leader   *leaderElector
+	throttle *loadThrottle
+	secrets  *secretStore
+	// replay answers HTTP checks from fixtures instead of the network.
+	replay *replayStore
+	// pagerDuty raises and resolves incidents on status changes.
+	pagerDuty *pagerDutyNotifier
+	// slack posts status changes to an incoming webhook when configured.
+	slack *slackNotifier
+	// email sends outage and recovery emails when SMTP is configured.
+	email *emailNotifier
+	// quietHours, when set, holds DEGRADED notifications of monitors without
+	// their own notification_schedule until it ends.
+	quietHours *severityWindow
+	// fleet reports changes of the aggregate status of all monitors.
+	fleet *fleetWebhook
+	// publisher streams results to Kafka when configured.
+	publisher *resultPublisher
+	// spans exports every check to an OpenTelemetry collector when
+	// configured.
+	spans *spanExporter
+	// results saves check results, retrying writes that fail.
+	results *resultStore
+	// metrics exposes check results to Prometheus when enabled.
+	metrics *checkMetrics
+	// lagAlarm follows how late scheduled checks start.
+	lagAlarm *scheduleLagAlarm
+	// pingMode is the kind of ICMP socket ping monitors use.
+	pingMode string
+	// interval is the default time between scheduled checks of a monitor.
+	interval time.Duration
+	// started is when scheduling began; no check is due before it.
+	started time.Time
+	// inFlight and dispatched hold, per monitor ID, scheduled checks still
+	// running and the time each monitor was last scheduled.
+	inFlight   sync.Map
+	dispatched sync.Map
+	// queuedRunning holds the IDs of queued checks this instance is running,
+	// so a tick does not start one twice.
+	queuedRunning sync.Map
+	// running counts check goroutines so shutdown can wait for them.
+	running sync.WaitGroup
+	// failureThreshold is how many checks in a row must fail before monitors
+	// without their own failure_threshold are reported.
+	failureThreshold int
+	// retries is how many times an UNHEALTHY probe is repeated for monitors
+	// without their own retry_count.
+	retries int
+	// slots holds one token per scheduled check in flight.
+	slots chan struct{}
+	// maxDuration is the absolute deadline of a check, whatever triggered it
+	// and however its probes are configured.
+	maxDuration time.Duration
+	// smoothing is the EWMA weight given to the newest latency sample.
+	smoothing float64
+	// certExpiryThreshold is how many days before expiry a certificate
+	// degrades monitors without their own cert_expiry_threshold_days.
+	certExpiryThreshold int
 }
 
 func newMonitorChecker(db *gorm.DB) *monitorChecker {
 	return &monitorChecker{
-		db:     db,
-		client: &http.Client{Timeout: 10 * time.Second},
+		db:                  db,
+		results:             newResultStore(db, defaultPersistRetryCount),
+		lagAlarm:            newScheduleLagAlarm(0),
+		client:              &http.Client{},
+		smoothing:           defaultResponseTimeSmoothing,
+		maxDuration:         defaultMaxCheckDuration,
+		slots:               make(chan struct{}, defaultMaxConcurrentChecks),
+		retries:             defaultRetryCount,
+		failureThreshold:    defaultFailureThreshold,
+		certExpiryThreshold: defaultCertExpiryThresholdDays,
 	}
 }
 
+// start schedules checks until ctx is cancelled. interval is the default for
+// monitors without their own interval_seconds.
 func (mc *monitorChecker) start(ctx context.Context, interval time.Duration) {
 	if interval <= 0 {
 		interval = 30 * time.Second
 	}
+	mc.interval = interval
+	mc.started = time.Now()
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := time.NewTicker(schedulerTick)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				mc.runBatch(ctx)
+				mc.results.flush()
+				if !mc.leader.leader() {
+					mc.fleet.reset()
+					continue
+				}
+				mc.runQueuedChecks()
+				if event, ok := mc.fleet.observe(mc.db); ok {
+					mc.spawn(func() { mc.fleet.send(event) })
+				}
+				if !mc.throttle.engage() {
+					mc.runBatch(ctx)
+				}
 			case <-ctx.Done():
 				return
 			}
@@ -79,250 +481,1937 @@ func (mc *monitorChecker) start(ctx context.Context, interval time.Duration) {
 	}()
 }
 
+// runBatch starts a check for every monitor whose interval has elapsed and
+// that is not still being checked. At most cap(mc.slots) run at once; the
+// batch waits for a free slot rather than skipping the rest. Each check
+// carries its scheduling lag, the time between when it was due and when it
+// got a slot, and the worst lag of the batch feeds the lag alarm.
 func (mc *monitorChecker) runBatch(ctx context.Context) {
 	var monitors []Monitor
-	if err := mc.db.Find(&monitors).Error; err != nil {
-		log.Printf("monitor batch query failed: %v", err)
+	if err := mc.db.Where("enabled = ?", true).Find(&monitors).Error; err != nil {
+		slog.Error("monitor batch query failed", "error", err)
 		return
 	}
+	now := time.Now()
+	var worst time.Duration
+	dispatched := false
 	for _, m := range monitors {
 		monitor := m
-		go mc.checkMonitor(ctx, &monitor)
+		due, ok := mc.claimDue(&monitor, now)
+		if !ok {
+			continue
+		}
+		select {
+		case mc.slots <- struct{}{}:
+		case <-ctx.Done():
+			mc.inFlight.Delete(monitor.ID)
+			return
+		}
+		lag := time.Since(due)
+		if lag < 0 {
+			lag = 0
+		}
+		if lag > worst {
+			worst = lag
+		}
+		dispatched = true
+		// A check already under way finishes even when ctx is cancelled for
+		// shutdown, so its result is not lost halfway through being saved.
+		mc.spawn(func() {
+			defer func() { <-mc.slots }()
+			defer mc.inFlight.Delete(monitor.ID)
+			mc.checkMonitor(withScheduleLag(context.WithoutCancel(ctx), lag), &monitor)
+		})
+	}
+	if dispatched && mc.lagAlarm.observe(worst) {
+		_, lagging := mc.lagAlarm.state()
+		mc.notifyScheduleLag(worst, lagging)
+	}
+}
+
+// claimDue reports whether monitor is due at now and, if so, marks it in
+// flight. The last dispatch time is remembered so a check that fails before
+// recording last_check is not retried on every tick.
+//
+// It also returns when the monitor became due: its interval after the last
+// check, but never before scheduling began or the monitor was last edited,
+// so downtime of the service or a shortened interval does not count as lag.
+func (mc *monitorChecker) claimDue(monitor *Monitor, now time.Time) (time.Time, bool) {
+	interval := mc.interval
+	if monitor.IntervalSeconds > 0 {
+		interval = time.Duration(monitor.IntervalSeconds) * time.Second
+	}
+	last := monitor.LastCheck
+	if dispatched, ok := mc.dispatched.Load(monitor.ID); ok && dispatched.(time.Time).After(last) {
+		last = dispatched.(time.Time)
+	}
+	if now.Sub(last) < interval {
+		return time.Time{}, false
+	}
+	if _, busy := mc.inFlight.LoadOrStore(monitor.ID, true); busy {
+		return time.Time{}, false
+	}
+	mc.dispatched.Store(monitor.ID, now)
+	due := last.Add(interval)
+	switch {
+	case last.IsZero():
+		due = now
+	case due.Before(mc.started):
+		due = mc.started
+	}
+	if due.Before(monitor.UpdatedAt) {
+		due = monitor.UpdatedAt
+	}
+	return due, true
+}
+
+// triggerCheck queues a check of the monitor. The leader runs it at once; a
+// standby leaves it queued for the leader to pick up on its next tick.
+func (mc *monitorChecker) triggerCheck(id uint) {
+	pending := PendingCheck{MonitorID: id}
+	if err := mc.db.Create(&pending).Error; err != nil {
+		slog.Error("failed to queue check", "monitor_id", id, "error", err)
+	}
+	if !mc.leader.leader() {
+		return
+	}
+	mc.startPendingCheck(pending)
+}
+
+// startPendingCheck runs a queued check in the background unless this
+// instance is already running it.
+func (mc *monitorChecker) startPendingCheck(pending PendingCheck) {
+	if pending.ID != 0 {
+		if _, running := mc.queuedRunning.LoadOrStore(pending.ID, struct{}{}); running {
+			return
+		}
+	}
+	mc.dispatched.Store(pending.MonitorID, time.Now())
+	mc.spawn(func() {
+		if pending.ID != 0 {
+			defer mc.queuedRunning.Delete(pending.ID)
+		}
+		mc.runPendingCheck(pending)
+	})
+}
+
+// spawn runs fn in a goroutine tracked by wait.
+func (mc *monitorChecker) spawn(fn func()) {
+	mc.running.Add(1)
+	go func() {
+		defer mc.running.Done()
+		fn()
+	}()
+}
+
+// wait blocks until every spawned check has finished or ctx expires.
+func (mc *monitorChecker) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		mc.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resumePendingChecks discards checks queued before the last shutdown that
+// are older than maxAge and, on the leader, runs the rest.
+func (mc *monitorChecker) resumePendingChecks(maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	result := mc.db.Where("created_at < ?", cutoff).Delete(&PendingCheck{})
+	if result.Error != nil {
+		slog.Error("stale pending check cleanup failed", "error", result.Error)
+	} else if result.RowsAffected > 0 {
+		slog.Info("discarded stale pending checks", "count", result.RowsAffected)
+	}
+	if mc.leader.leader() {
+		mc.runQueuedChecks()
+	}
+}
+
+// runQueuedChecks starts every queued check this instance is not already
+// running, including those queued by standbys. Only the first of several
+// queued for the same monitor runs; the others are dropped.
+func (mc *monitorChecker) runQueuedChecks() {
+	var pending []PendingCheck
+	if err := mc.db.Order("id asc").Find(&pending).Error; err != nil {
+		slog.Error("pending check query failed", "error", err)
+		return
+	}
+	queued := make(map[uint]bool, len(pending))
+	for _, p := range pending {
+		if _, running := mc.queuedRunning.Load(p.ID); running {
+			continue
+		}
+		if queued[p.MonitorID] {
+			mc.db.Delete(&PendingCheck{}, p.ID)
+			continue
+		}
+		queued[p.MonitorID] = true
+		mc.startPendingCheck(p)
+	}
+}
+
+func (mc *monitorChecker) runPendingCheck(pending PendingCheck) {
+	if pending.ID != 0 {
+		defer func() {
+			if err := mc.db.Delete(&PendingCheck{}, pending.ID).Error; err != nil {
+				slog.Error("failed to clear pending check", "pending_check_id", pending.ID, "monitor_id", pending.MonitorID, "error", err)
+			}
+		}()
+	}
+	var monitor Monitor
+	if err := mc.db.First(&monitor, pending.MonitorID).Error; err != nil {
+		slog.Error("monitor trigger failed", "monitor_id", pending.MonitorID, "error", err)
+		return
+	}
+	if !monitor.Enabled {
+		return
+	}
+	mc.checkMonitor(context.Background(), &monitor)
+}
+
+// smoothResponseTime folds the latest latency into the monitor's exponentially
+// weighted moving average. Failed probes carry no meaningful latency and are
+// skipped.
+func (mc *monitorChecker) smoothResponseTime(monitor *Monitor, update map[string]interface{}) {
+	if category, _ := update["last_error_category"].(string); category != "" {
+		return
+	}
+	latency, ok := update["last_response_time_ms"].(int)
+	if !ok {
+		return
+	}
+	smoothed := float64(latency)
+	if monitor.SmoothedResponseTimeMs > 0 {
+		smoothed = mc.smoothing*float64(latency) + (1-mc.smoothing)*monitor.SmoothedResponseTimeMs
+	}
+	update["smoothed_response_time_ms"] = smoothed
+}
+
+// countFailures tracks how many checks in a row have not been HEALTHY. It
+// runs before confirmRecovery so healthy probes during a confirmation period
+// end the streak even while the reported status is held.
+func countFailures(monitor *Monitor, update map[string]interface{}) {
+	status, ok := update["status"].(string)
+	if !ok {
+		return
+	}
+	if status == statusHealthy {
+		update["consecutive_failures"] = 0
+		return
+	}
+	update["consecutive_failures"] = monitor.ConsecutiveFailures + 1
+}
+
+// resolveSecrets returns a copy of monitor whose URL and header values have
+// their ${NAME} placeholders filled in, or monitor itself when they have
+// none. The copy is only used for the probe and is never saved.
+func (mc *monitorChecker) resolveSecrets(monitor *Monitor) (*Monitor, error) {
+	headerSecrets := false
+	for _, value := range monitor.Headers {
+		if secretPlaceholder.MatchString(value) {
+			headerSecrets = true
+			break
+		}
+	}
+	if !secretPlaceholder.MatchString(monitor.URL) && !headerSecrets {
+		return monitor, nil
+	}
+	resolved, err := mc.secrets.expand(monitor.URL)
+	if err != nil {
+		return nil, err
+	}
+	target := *monitor
+	target.URL = resolved
+	if headerSecrets {
+		target.Headers = make(stringMap, len(monitor.Headers))
+		for name, value := range monitor.Headers {
+			if target.Headers[name], err = mc.secrets.expand(value); err != nil {
+				return nil, fmt.Errorf("header %s: %v", name, err)
+			}
+		}
+	}
+	return &target, nil
+}
+
+// clientFor returns the HTTP client to use for a monitor and a function that
+// releases any per-monitor resources once the check is done. A non-empty ip
+// pins the connection to that address. Monitors without transport overrides
+// share mc.client.
+func (mc *monitorChecker) clientFor(monitor *Monitor, ip string) (*http.Client, func()) {
+	if mc.replay != nil {
+		return &http.Client{Transport: mc.replay}, func() {}
+	}
+	if monitor.SNIHostname == "" && ip == "" && !monitor.usesCustomProfile() {
+		return mc.client, func() {}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if monitor.SNIHostname != "" {
+		transport.TLSClientConfig = &tls.Config{ServerName: monitor.SNIHostname}
+	}
+	if ip != "" {
+		transport.DialContext = pinnedDialer(ip)
+	}
+	applyClientProfile(monitor.ClientProfile, transport)
+	client := &http.Client{Transport: transport}
+	return client, transport.CloseIdleConnections
+}
+
+func (mc *monitorChecker) checkMonitor(ctx context.Context, monitor *Monitor) {
+	// Every probe honours ctx, so the deadline releases a hung connection and
+	// its goroutine even when no lower timeout applies. Each attempt also has
+	// the monitor's own timeout; the checker's clients set none of their own.
+	ctx, cancel := context.WithTimeout(ctx, mc.maxDuration)
+	defer cancel()
+	ctx, span := mc.spans.begin(ctx)
+
+	var update map[string]interface{}
+	sampled, path := monitor.samplePath()
+	target, err := mc.resolveSecrets(sampled)
+	if err != nil {
+		slog.Error("monitor cannot resolve its secrets", "monitor_id", monitor.ID, "error", err)
+		update = map[string]interface{}{
+			"status":              statusUnhealthy,
+			"last_check":          time.Now(),
+			"last_error":          err.Error(),
+			"last_error_category": errorCategoryOther,
+		}
+	} else {
+		primaryCtx, cancelPrimary := mc.primaryContext(ctx, monitor)
+		primary := mc.probeWithRetries(primaryCtx, target)
+		cancelPrimary()
+		update = mc.tryFallback(ctx, monitor, target, primary)
+	}
+	if update == nil {
+		return
+	}
+	mc.secrets.redactUpdate(update)
+	if update["last_error_category"] == errorCategoryCanceled {
+		// Keep the previous status and metrics; only note the abandoned attempt.
+		update = map[string]interface{}{
+			"last_check":          update["last_check"],
+			"last_error":          update["last_error"],
+			"last_error_category": errorCategoryCanceled,
+		}
+	}
+	if lag, ok := scheduleLag(ctx); ok {
+		update["last_schedule_lag_ms"] = int(lag / time.Millisecond)
+	}
+	recordSampledPath(monitor, path, update)
+	applyErrorRate(monitor, update)
+	mc.detectLatencyAnomaly(monitor, update)
+	checkJitter(monitor, update)
+	mc.smoothResponseTime(monitor, update)
+	countFailures(monitor, update)
+	confirmRecovery(monitor, update)
+	accumulateBandwidth(update)
+	slog.Debug("check completed",
+		"monitor_id", monitor.ID,
+		"status", update["status"],
+		"code", update["last_response_code"],
+		"latency_ms", update["last_response_time_ms"],
+	)
+	previous, severity, alert := mc.alertTransition(monitor, update)
+	mc.results.save(monitor.ID, update)
+	mc.metrics.observe(monitor, update)
+	mc.publisher.publish(monitor, update)
+	mc.spans.finish(span, monitor, update)
+	if alert {
+		mc.notify(monitor, previous, severity, update)
+	}
+}
+
+// checkHTTP probes an HTTP(S) monitor and returns the column updates to
+// persist, or nil when no request could be made. A non-empty ip probes that
+// address instead of resolving the URL's host.
+func (mc *monitorChecker) checkHTTP(ctx context.Context, monitor *Monitor, ip string) map[string]interface{} {
+	req, err := newCheckRequest(ctx, monitor)
+	if err != nil {
+		slog.Error("failed to build request", "monitor_id", monitor.ID, "error", err)
+		return nil
+	}
+	applyProfileHeaders(monitor.ClientProfile, req)
+	if monitor.needsBody() && monitor.ClientProfile != clientProfileMinimal {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	applyRequestHeaders(monitor.Headers, req)
+	userAgent := monitor.nextUserAgent()
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	var trace *checkTrace
+	span := spanFromContext(ctx)
+	if monitor.DebugLogging || span != nil {
+		trace = newCheckTrace()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	}
+	start := time.Now()
+	status := statusUnhealthy
+	code := 0
+	latency := 0
+	fingerprint := ""
+	expiryDays, expiryKnown := 0, false
+	lastError := ""
+	errorCategory := ""
+	var headers http.Header
+	var body []byte
+	client, release := mc.clientFor(monitor, ip)
+	defer release()
+	bytesSent := requestWireSize(req)
+	bytesReceived := 0
+	resp, err := client.Do(req)
+	if trace != nil && monitor.DebugLogging {
+		trace.log(monitor, ip, req, resp, err)
+	}
+	span.addTrace(trace, ip)
+	if err != nil {
+		slog.Error("request failed", "monitor_id", monitor.ID, "error", err)
+		lastError = err.Error()
+		errorCategory = classifyRequestError(err)
+		expiryDays, expiryKnown = failedCertExpiryDays(err)
+	} else {
+		code = resp.StatusCode
+		latency = int(time.Since(start) / time.Millisecond)
+		fingerprint = leafCertFingerprint(resp.TLS)
+		expiryDays, expiryKnown = certExpiryDays(resp.TLS), true
+		headers = resp.Header
+		counted := &countingReader{ReadCloser: resp.Body}
+		resp.Body = counted
+		if monitor.needsBody() {
+			body, err = readDecodedBody(resp)
+			if err != nil {
+				slog.Error("body read failed", "monitor_id", monitor.ID, "error", err)
+				body = nil
+			}
+		}
+		resp.Body.Close()
+		bytesReceived = responseWireSize(resp, counted.n)
+		status = deriveStatus(monitor, code, latency)
+		if slowResponse(monitor, code, latency) {
+			lastError = fmt.Sprintf("response took %d ms, above the %d ms threshold", latency, monitor.DegradedThresholdMs)
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", lastError)
+		} else if monitor.ExpectedStatusCodes != "" && !monitor.expectedCode(code) {
+			lastError = fmt.Sprintf("status code %d is not one of %s", code, monitor.ExpectedStatusCodes)
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", lastError)
+		}
+	}
+	update := map[string]interface{}{
+		"last_check":            time.Now(),
+		"last_response_code":    code,
+		"last_response_time_ms": latency,
+		"last_error":            lastError,
+		"last_error_category":   errorCategory,
+		"last_bytes_sent":       bytesSent,
+		"last_bytes_received":   bytesReceived,
+	}
+	if userAgent != "" {
+		update["last_user_agent"] = userAgent
+		update["user_agent_rotation"] = monitor.UserAgentRotation + 1
+	}
+	if fingerprint != "" {
+		update["last_cert_fingerprint"] = fingerprint
+		if monitor.CertPinned {
+			if monitor.CertFingerprint == "" {
+				// Trust on first use: the first certificate seen becomes the baseline.
+				update["cert_fingerprint"] = fingerprint
+			} else if monitor.CertFingerprint != fingerprint {
+				slog.Error("certificate changed", "monitor_id", monitor.ID, "expected", monitor.CertFingerprint, "got", fingerprint)
+				status = worseStatus(status, statusDegraded)
+			}
+		}
+	}
+	if expiryKnown {
+		update["cert_expiry_days"] = expiryDays
+		threshold := mc.certExpiryThresholdFor(monitor)
+		if resp != nil && expiryDays != noCertExpiry && expiryDays < threshold {
+			failure := fmt.Sprintf("certificate expires in %d days, within the %d day threshold", expiryDays, threshold)
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", failure)
+			status = worseStatus(status, statusDegraded)
+			update["last_error"] = failure
+		}
+	}
+	if body != nil {
+		update["last_content_length"] = len(body)
+		body = transformBody(monitor, body)
+	}
+	if body != nil && monitor.ChangeThresholdPercent > 0 {
+		current := string(body)
+		if monitor.LastBody != "" {
+			change := contentChangePercent(monitor.LastBody, current)
+			update["last_change_percent"] = change
+			if change > monitor.ChangeThresholdPercent {
+				slog.Error("content changed", "monitor_id", monitor.ID, "change_percent", change)
+				status = worseStatus(status, statusDegraded)
+			}
+		}
+		update["last_body"] = current
+	}
+	if body != nil && monitor.isType(monitorTypeFeed) {
+		maxAge := defaultFeedMaxAge
+		if monitor.FeedMaxAgeSeconds > 0 {
+			maxAge = time.Duration(monitor.FeedMaxAgeSeconds) * time.Second
+		}
+		latest, err := latestFeedEntry(body)
+		if err != nil {
+			slog.Error("feed parse failed", "monitor_id", monitor.ID, "error", err)
+			status = worseStatus(status, statusDegraded)
+		} else {
+			update["latest_entry_at"] = latest
+			if time.Since(latest) > maxAge {
+				slog.Error("feed is stale", "monitor_id", monitor.ID, "latest_entry_at", latest.Format(time.RFC3339))
+				status = worseStatus(status, statusDegraded)
+			}
+		}
+	}
+	if body != nil && monitor.HealthBoolPath != "" {
+		status = worseStatus(status, evaluateHealthBool(monitor, body))
+	}
+	if body != nil && monitor.XPath != "" {
+		xpathStatus, failure := evaluateXPathAssertion(monitor, body)
+		if failure != "" {
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", failure)
+			update["last_error"] = failure
+		}
+		status = worseStatus(status, xpathStatus)
+	}
+	// An unreadable body cannot contain the keyword either.
+	if code != 0 && monitor.ExpectedKeyword != "" && !bytes.Contains(body, []byte(monitor.ExpectedKeyword)) {
+		failure := fmt.Sprintf("response does not contain %q", monitor.ExpectedKeyword)
+		slog.Error("check failed", "monitor_id", monitor.ID, "error", failure)
+		status = statusUnhealthy
+		update["last_error"] = failure
+	}
+	if headers != nil && len(monitor.HeaderAssertions) > 0 {
+		if failure := checkHeaderAssertions(monitor.HeaderAssertions, headers); failure != "" {
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", failure)
+			status = worseStatus(status, statusDegraded)
+			update["last_error"] = failure
+		}
+	}
+	if headers != nil && monitor.RegionHeader != "" {
+		observed, failure := checkRegion(monitor, headers)
+		update["last_region"] = observed
+		if failure != "" {
+			slog.Error("check failed", "monitor_id", monitor.ID, "error", failure)
+			status = worseStatus(status, statusDegraded)
+			update["last_error"] = failure
+		}
+	}
+	update["status"] = status
+	return update
+}
+
+// classifyRequestError distinguishes latency-induced timeouts from hard
+// failures such as DNS errors, refused connections, and TLS problems.
+func classifyRequestError(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errorCategoryCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorCategoryTimeout
+	case errors.As(err, &dnsErr):
+		return errorCategoryDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorCategoryRefused
+	case errors.As(err, &certErr), errors.As(err, &recordErr):
+		return errorCategoryTLS
+	default:
+		return errorCategoryOther
+	}
+}
+
+// leafCertFingerprint returns the hex SHA-256 of the peer's leaf certificate,
+// or an empty string when the connection was not TLS.
+func leafCertFingerprint(state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
 	}
+	sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:])
 }
 
-func (mc *monitorChecker) triggerCheck(id uint) {
-	go func() {
-		var monitor Monitor
-		if err := mc.db.First(&monitor, id).Error; err != nil {
-			log.Printf("monitor trigger failed for id=%d: %v", id, err)
+// contentChangePercent estimates how much of a body changed between two checks
+// as the percentage of lines not shared between them (0 = identical).
+func contentChangePercent(previous, current string) float64 {
+	prevLines := strings.Split(previous, "\n")
+	currLines := strings.Split(current, "\n")
+	counts := make(map[string]int, len(prevLines))
+	for _, line := range prevLines {
+		counts[line]++
+	}
+	common := 0
+	for _, line := range currLines {
+		if counts[line] > 0 {
+			counts[line]--
+			common++
+		}
+	}
+	total := len(prevLines) + len(currLines)
+	return float64(total-2*common) / float64(total) * 100
+}
+
+// evaluateHealthBool maps the boolean at the monitor's health_bool_path to a
+// status: true is HEALTHY, false is UNHEALTHY, and anything else is DEGRADED.
+func evaluateHealthBool(monitor *Monitor, body []byte) string {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		slog.Error("response is not valid JSON", "monitor_id", monitor.ID, "error", err)
+		return statusDegraded
+	}
+	value, ok := lookupJSONPath(doc, monitor.HealthBoolPath)
+	if !ok {
+		slog.Error("health path not found", "monitor_id", monitor.ID, "health_bool_path", monitor.HealthBoolPath)
+		return statusDegraded
+	}
+	healthy, ok := value.(bool)
+	if !ok {
+		slog.Error("health path is not a boolean", "monitor_id", monitor.ID, "health_bool_path", monitor.HealthBoolPath)
+		return statusDegraded
+	}
+	if !healthy {
+		return statusUnhealthy
+	}
+	return statusHealthy
+}
+
+// evaluateXPathAssertion checks the value at the monitor's xpath. A parse
+// failure or missing node is DEGRADED; a value other than xpath_expected is
+// UNHEALTHY. The returned message describes any failure.
+func evaluateXPathAssertion(monitor *Monitor, body []byte) (string, string) {
+	value, found, err := evaluateXPath(body, monitor.XPath)
+	if err != nil {
+		return statusDegraded, fmt.Sprintf("xpath %s: %v", monitor.XPath, err)
+	}
+	if !found {
+		return statusDegraded, fmt.Sprintf("xpath %s: not found", monitor.XPath)
+	}
+	if monitor.XPathExpected != "" && value != monitor.XPathExpected {
+		return statusUnhealthy, fmt.Sprintf("xpath %s: expected %q, got %q", monitor.XPath, monitor.XPathExpected, value)
+	}
+	return statusHealthy, ""
+}
+
+// lookupJSONPath walks a decoded JSON document along a dot-separated path.
+// Numeric segments index into arrays, e.g. "data.checks.0.healthy".
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// validJSONPath reports whether path is a non-empty dot-separated path
+// without empty segments.
+func validJSONPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if strings.TrimSpace(segment) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHeaderAssertions compares response headers with the expected values.
+// An expected value wrapped in slashes, e.g. "/^edge-\d+$/", is a regular
+// expression; anything else must match exactly. It returns a description of
+// the first failing header, or an empty string when all assertions pass.
+func checkHeaderAssertions(assertions map[string]string, headers http.Header) string {
+	names := make([]string, 0, len(assertions))
+	for name := range assertions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expected := assertions[name]
+		values, present := headers[http.CanonicalHeaderKey(name)]
+		actual := headers.Get(name)
+		if !present {
+			return fmt.Sprintf("header %s: expected %q, header missing", name, expected)
+		}
+		if pattern, ok := headerPattern(expected); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil || !re.MatchString(actual) {
+				return fmt.Sprintf("header %s: %q does not match %s", name, actual, expected)
+			}
+			continue
+		}
+		if !containsString(values, expected) {
+			return fmt.Sprintf("header %s: expected %q, got %q", name, expected, actual)
+		}
+	}
+	return ""
+}
+
+// headerPattern extracts the regular expression from a /pattern/ assertion.
+func headerPattern(expected string) (string, bool) {
+	if len(expected) >= 2 && strings.HasPrefix(expected, "/") && strings.HasSuffix(expected, "/") {
+		return expected[1 : len(expected)-1], true
+	}
+	return "", false
+}
+
+// validateHeaderAssertions checks header names and compiles regex values.
+func validateHeaderAssertions(assertions map[string]string) error {
+	for name, expected := range assertions {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if pattern, ok := headerPattern(expected); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid pattern for header %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name
+// (an RFC 7230 token).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= 0x20 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// rollupStatus combines several statuses into one: HEALTHY when all are
+// healthy, UNKNOWN when all are unknown (or there are none), UNHEALTHY when
+// none are healthy or degraded, and DEGRADED otherwise.
+func rollupStatus(statuses []string) string {
+	healthy := 0
+	degraded := 0
+	unknown := 0
+	for _, status := range statuses {
+		switch status {
+		case statusHealthy:
+			healthy++
+		case statusDegraded:
+			degraded++
+		case statusUnknown:
+			unknown++
+		}
+	}
+
+	if len(statuses) == 0 {
+		return statusUnknown
+	} else if healthy == len(statuses) {
+		return statusHealthy
+	} else if healthy == 0 && degraded == 0 && unknown == len(statuses) {
+		return statusUnknown
+	} else if healthy == 0 && degraded == 0 {
+		return statusUnhealthy
+	}
+	return statusDegraded
+}
+
+// worseStatus returns whichever of the two statuses is more severe.
+func worseStatus(a, b string) string {
+	rank := map[string]int{statusHealthy: 0, statusUnknown: 1, statusDegraded: 2, statusUnhealthy: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// validStatus reports whether status is one of the status constants.
+func validStatus(status string) bool {
+	switch status {
+	case statusHealthy, statusDegraded, statusUnhealthy, statusUnknown:
+		return true
+	}
+	return false
+}
+
+// deriveStatus maps a response to a status. An expected code slower than the
+// monitor's degraded_threshold_ms (when positive) is DEGRADED. Without
+// expected_status_codes, 4xx is DEGRADED and other codes UNHEALTHY; with
+// them, any code outside the list is UNHEALTHY.
+func deriveStatus(monitor *Monitor, code, latencyMs int) string {
+	switch {
+	case slowResponse(monitor, code, latencyMs):
+		return statusDegraded
+	case monitor.expectedCode(code):
+		return statusHealthy
+	case monitor.ExpectedStatusCodes == "" && code >= 400 && code < 500:
+		return statusDegraded
+	default:
+		return statusUnhealthy
+	}
+}
+
+// slowResponse reports whether an otherwise healthy response exceeded the
+// monitor's latency threshold.
+func slowResponse(monitor *Monitor, code, latencyMs int) bool {
+	return monitor.expectedCode(code) && monitor.DegradedThresholdMs > 0 && latencyMs > monitor.DegradedThresholdMs
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	readKey := strings.TrimSpace(getEnv("READ_KEY"))
+	adminKey := strings.TrimSpace(getEnv("ADMIN_KEY"))
+	superadminKey := strings.TrimSpace(getEnv("SUPERADMIN_KEY"))
+
+	if readKey == "" || adminKey == "" {
+		log.Fatal("READ_KEY and ADMIN_KEY must be provided via environment variables")
+	}
+
+	keys, err := newAPIKeys(readKey, adminKey, superadminKey, strings.TrimSpace(getEnv("PUBLIC_READ_KEY")))
+	if err != nil {
+		log.Fatalf("invalid key configuration: %v", err)
+	}
+
+	db, err := openDatabase(getEnv("DB_DRIVER"), strings.TrimSpace(getEnv("DATABASE_URL")), strings.TrimSpace(getEnv("DB_PATH")))
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+
+	trackedAlerts := db.Migrator().HasColumn(&Monitor{}, "alerted_status")
+	if err := db.AutoMigrate(&Monitor{}, &IdempotencyKey{}, &PendingCheck{}, &LeaderLease{}, &MonitorTemplate{}, &MonitorGroup{}, &MonitorGroupMember{}, &CheckResult{}); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// Monitors created before ownership existed belong to the first tenant.
+	if err := db.Model(&Monitor{}).Where("owner_key = '' OR owner_key IS NULL").UpdateColumn("owner_key", keys.defaultOwner).Error; err != nil {
+		log.Fatalf("failed to assign monitor owners: %v", err)
+	}
+	// Monitors created before timestamps existed date from their first recorded
+	// check, or from now when they have no history.
+	if err := db.Model(&Monitor{}).Where("created_at IS NULL").UpdateColumn("created_at", gorm.Expr("COALESCE((SELECT MIN(checked_at) FROM check_results WHERE check_results.monitor_id = monitors.id), ?)", time.Now())).Error; err != nil {
+		log.Fatalf("failed to backfill monitor creation times: %v", err)
+	}
+	if err := db.Model(&Monitor{}).Where("updated_at IS NULL").UpdateColumn("updated_at", gorm.Expr("created_at")).Error; err != nil {
+		log.Fatalf("failed to backfill monitor update times: %v", err)
+	}
+
+	if err := normalizeMonitorTypes(db); err != nil {
+		log.Fatalf("failed to normalize monitor types: %v", err)
+	}
+
+	// Monitors failing before alerts were tracked were reported when they
+	// started failing, so their recovery should be too.
+	if !trackedAlerts {
+		if err := db.Model(&Monitor{}).Where("status IN ?", []string{statusDegraded, statusUnhealthy}).UpdateColumn("alerted_status", gorm.Expr("status")).Error; err != nil {
+			log.Fatalf("failed to backfill alerted statuses: %v", err)
+		}
+	}
+
+	secrets, err := loadSecretStore(strings.TrimSpace(getEnv("SECRETS_FILE")))
+	if err != nil {
+		log.Fatalf("failed to load secrets file: %v", err)
+	}
+	logLevel, err := parseLogLevel(getEnv("LOG_LEVEL"))
+	if err != nil {
+		log.Fatalf("invalid LOG_LEVEL: %v", err)
+	}
+	slog.SetDefault(newLogger(os.Stderr, logLevel, secrets))
+
+	checker := newMonitorChecker(db)
+	checker.secrets = secrets
+	// The publisher and span exporter outlive ctx so results of checks
+	// finishing during shutdown are still delivered.
+	publisherCtx, stopPublisher := context.WithCancel(context.Background())
+	defer stopPublisher()
+	replay, err := loadReplayStore(strings.TrimSpace(getEnv("CHECK_REPLAY_FIXTURES")))
+	if err != nil {
+		log.Fatalf("failed to load replay fixtures: %v", err)
+	}
+	if replay != nil {
+		checker.replay = replay
+		slog.Warn("replay mode is on; HTTP checks are answered from fixtures and no monitor is actually probed", "path", replay.path)
+	}
+	checker.pagerDuty = newPagerDutyNotifier(strings.TrimSpace(getEnv("PAGERDUTY_EVENTS_URL")), strings.TrimSpace(getEnv("PAGERDUTY_ROUTING_KEY")))
+	checker.lagAlarm = newScheduleLagAlarm(getEnvAsDuration("SCHEDULE_LAG_THRESHOLD", 0))
+	if getEnvAsBool("METRICS_ENABLED", false) {
+		checker.metrics = newCheckMetrics()
+	}
+	checker.slack = newSlackNotifier(strings.TrimSpace(getEnv("SLACK_WEBHOOK_URL")))
+	checker.email = newEmailNotifier(
+		strings.TrimSpace(getEnv("SMTP_HOST")),
+		strings.TrimSpace(getEnv("SMTP_PORT")),
+		getEnv("SMTP_USER"),
+		getEnv("SMTP_PASS"),
+		strings.TrimSpace(getEnv("ALERT_FROM")),
+		splitList(getEnv("ALERT_TO")),
+	)
+	quietHours, err := parseQuietHours(getEnv("QUIET_HOURS"))
+	if err != nil {
+		log.Fatalf("invalid QUIET_HOURS: %v", err)
+	}
+	checker.quietHours = quietHours
+	checker.fleet = newFleetWebhook(strings.TrimSpace(getEnv("FLEET_WEBHOOK_URL")), getEnv("FLEET_WEBHOOK_SECRET"), getEnvAsDuration("FLEET_WEBHOOK_COOLDOWN", defaultFleetWebhookCooldown))
+	checker.pingMode = detectPingMode()
+	slog.Info("ping monitors use " + checker.pingMode + " ICMP sockets")
+	if brokers := splitList(getEnv("KAFKA_BROKERS")); len(brokers) > 0 {
+		topic := strings.TrimSpace(getEnv("KAFKA_TOPIC"))
+		if topic == "" {
+			log.Fatal("KAFKA_TOPIC must be set when KAFKA_BROKERS is provided")
+		}
+		checker.publisher = newResultPublisher(brokers, topic, getEnvAsInt("KAFKA_BUFFER_SIZE", 1000))
+		checker.publisher.start(publisherCtx)
+	}
+	checker.spans = newSpanExporter(
+		strings.TrimSpace(getEnv("OTEL_EXPORTER_OTLP_ENDPOINT")),
+		strings.TrimSpace(getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")),
+		getEnv("OTEL_EXPORTER_OTLP_HEADERS"),
+		strings.TrimSpace(getEnv("OTEL_SERVICE_NAME")),
+	)
+	checker.spans.start(publisherCtx)
+	if limit := getEnvAsInt("MAX_CONCURRENT_CHECKS", defaultMaxConcurrentChecks); limit > 0 {
+		checker.slots = make(chan struct{}, limit)
+	}
+	if retries := getEnvAsInt("CHECK_RETRY_COUNT", defaultRetryCount); retries >= 0 && retries <= maxRetryCount {
+		checker.retries = retries
+	}
+	if threshold := getEnvAsInt("FAILURE_THRESHOLD", defaultFailureThreshold); threshold >= 1 && threshold <= maxFailureThreshold {
+		checker.failureThreshold = threshold
+	}
+	if retries := getEnvAsInt("PERSIST_RETRY_COUNT", defaultPersistRetryCount); retries >= 0 && retries <= maxPersistRetryCount {
+		checker.results.retries = retries
+	}
+	if days := getEnvAsInt("CERT_EXPIRY_THRESHOLD_DAYS", defaultCertExpiryThresholdDays); days >= 0 && days <= maxCertExpiryThresholdDays {
+		checker.certExpiryThreshold = days
+	}
+	if maxDuration := getEnvAsDuration("MAX_CHECK_DURATION", defaultMaxCheckDuration); maxDuration > 0 {
+		checker.maxDuration = maxDuration
+	}
+	if smoothing := getEnvAsFloat("RESPONSE_TIME_SMOOTHING", defaultResponseTimeSmoothing); smoothing > 0 && smoothing <= 1 {
+		checker.smoothing = smoothing
+	}
+	checker.throttle = newLoadThrottle(getEnvAsInt("THROTTLE_MAX_GOROUTINES", 0), getEnvAsFloat("THROTTLE_MAX_CPU_PERCENT", 0))
+	if getEnvAsBool("LEADER_ELECTION", false) {
+		lease := time.Duration(getEnvAsInt("LEADER_LEASE_SECONDS", 15)) * time.Second
+		checker.leader = newLeaderElector(db, strings.TrimSpace(getEnv("INSTANCE_ID")), lease)
+		checker.leader.start(ctx)
+	}
+	interval := time.Duration(getEnvAsInt("CHECK_INTERVAL_SECONDS", 30)) * time.Second
+	scoreWeights := healthScoreWeightsFromEnv()
+	canary := newStartupCanary(strings.TrimSpace(getEnv("STARTUP_CANARY_URL")), &http.Client{Timeout: defaultCheckTimeout})
+	if canary != nil {
+		if err := canary.run(ctx, getEnvAsBool("STARTUP_CANARY_REQUIRED", false), interval); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	pendingMaxAge := time.Duration(getEnvAsInt("PENDING_CHECK_MAX_AGE_SECONDS", 3600)) * time.Second
+	checker.resumePendingChecks(pendingMaxAge)
+	checker.start(ctx, interval)
+
+	idempotencyWindow := time.Duration(getEnvAsInt("IDEMPOTENCY_WINDOW_SECONDS", 86400)) * time.Second
+	autoName := getEnvAsBool("AUTO_NAME_MONITORS", false)
+
+	router := gin.New()
+	router.Use(requestLogger(), gin.Recovery())
+
+	router.GET("/healthz", func(c *gin.Context) {
+		throttled, reason := checker.throttle.state()
+		ready, canaryErr := canary.state()
+		failedPersists, pendingPersists, droppedPersists := checker.results.state()
+		scheduleLag, lagging := checker.lagAlarm.state()
+		code := http.StatusOK
+		statusText := "ok"
+		if !ready {
+			code = http.StatusServiceUnavailable
+			statusText = "not ready"
+		}
+		c.JSON(code, gin.H{
+			"status":           statusText,
+			"ready":            ready,
+			"canary_error":     canaryErr,
+			"role":             checker.leader.role(),
+			"throttled":        throttled,
+			"throttle_reason":  reason,
+			"ping_mode":        checker.pingMode,
+			"replay":           checker.replay != nil,
+			"failed_persists":  failedPersists,
+			"pending_persists": pendingPersists,
+			"dropped_persists": droppedPersists,
+			"schedule_lag_ms":  int(scheduleLag / time.Millisecond),
+			"schedule_lagging": lagging,
+		})
+	})
+
+	router.GET("/monitor", authorize(keys, true), func(c *gin.Context) {
+		page, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		status := strings.ToUpper(strings.TrimSpace(c.Query("status")))
+		if status != "" && !validStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "status must be one of HEALTHY, DEGRADED, UNHEALTHY, UNKNOWN"})
+			return
+		}
+		typeValue := strings.ToLower(strings.TrimSpace(c.Query("type")))
+		filtered := func() *gorm.DB {
+			query := ownedMonitors(c, db)
+			if status != "" {
+				query = query.Where("status = ?", status)
+			}
+			if typeValue != "" {
+				query = query.Where("LOWER(TRIM(type)) = ?", typeValue)
+			}
+			return query
+		}
+
+		var total int64
+		if err := filtered().Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch monitors"})
+			return
+		}
+		monitors := []Monitor{}
+		if err := page.apply(filtered().Order("id asc")).Find(&monitors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch monitors"})
+			return
+		}
+		page.setHeaders(c, total)
+		c.JSON(http.StatusOK, monitors)
+	})
+
+	router.POST("/monitor", authorize(keys, false), func(c *gin.Context) {
+		idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Idempotency-Key is too long"})
+			return
+		}
+		if idempotencyKey != "" {
+			// Keys are namespaced per owner so tenants cannot replay each other's monitors.
+			idempotencyKey = c.GetString(ownerContextKey) + ":" + idempotencyKey
+		}
+		if idempotencyKey != "" {
+			if monitor, ok := findIdempotentMonitor(db, idempotencyKey, idempotencyWindow); ok {
+				c.Header("Idempotent-Replayed", "true")
+				c.JSON(http.StatusCreated, monitor)
+				return
+			}
+		}
+
+		var req monitorCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+
+		name := strings.TrimSpace(req.Name)
+		typeValue := strings.TrimSpace(req.Type)
+		urlValue := strings.TrimSpace(req.URL)
+		if (name == "" && !autoName) || typeValue == "" || urlValue == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Name, type, and url are required"})
+			return
+		}
+		if err := validateMonitorType(typeValue); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if err := validateTarget(typeValue, urlValue); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid URL"})
+			return
+		}
+		if name == "" {
+			generated, err := uniqueMonitorName(ownedMonitors(c, db), autoMonitorName(urlValue))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to name monitor"})
+				return
+			}
+			name = generated
+		}
+		if !validPercent(req.ChangeThresholdPercent) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "change_threshold_percent must be between 0 and 100"})
+			return
+		}
+		if req.FeedMaxAgeSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "feed_max_age_seconds cannot be negative"})
+			return
+		}
+		if req.Priority < 0 || req.Priority > maxMonitorPriority {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "priority must be between 0 and 10"})
+			return
+		}
+		if _, err := decodeUDPPayload(req.UDPPayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid udp_payload"})
+			return
+		}
+		if req.AnomalyZScoreThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "anomaly_z_score_threshold cannot be negative"})
+			return
+		}
+		if req.RecoveryConfirmationSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "recovery_confirmation_seconds cannot be negative"})
+			return
+		}
+		if req.IntervalSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "interval_seconds cannot be negative"})
+			return
+		}
+		if req.MaxJitterMs < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "max_jitter_ms cannot be negative"})
+			return
+		}
+		if req.TimeoutSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "timeout_seconds cannot be negative"})
+			return
+		}
+		if req.ColdTimeoutSeconds < 0 || req.ColdAfterSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "cold_timeout_seconds and cold_after_seconds cannot be negative"})
+			return
+		}
+		expectedStatusCodes, err := normalizeStatusCodes(req.ExpectedStatusCodes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		method, err := normalizeMethod(req.Method)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if err := validateRequestBody(method, req.RequestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		samplePaths := normalizeList(req.SamplePaths)
+		pathSampling := strings.ToLower(strings.TrimSpace(req.PathSampling))
+		if err := validateSamplePaths(samplePaths, pathSampling); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if err := validateRequestHeaders(req.Headers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		steps, err := normalizeSteps(typeValue, req.Steps)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		fallbackURL := strings.TrimSpace(req.FallbackURL)
+		if err := validateFallbackURL(typeValue, fallbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		notificationTimezone := strings.TrimSpace(req.NotificationTimezone)
+		notificationSchedule, err := normalizeSeveritySchedule(req.NotificationSchedule, notificationTimezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if req.DegradedThresholdMs < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "degraded_threshold_ms cannot be negative"})
+			return
+		}
+		if req.ErrorRateWindow < 0 || req.ErrorRateWindow > maxErrorRateWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("error_rate_window must be between 0 and %d", maxErrorRateWindow)})
+			return
+		}
+		if !validPercent(req.ErrorRateDegradedPercent) || !validPercent(req.ErrorRateUnhealthyPercent) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "error rate thresholds must be between 0 and 100"})
+			return
+		}
+		if req.RetryCount != nil && (*req.RetryCount < 0 || *req.RetryCount > maxRetryCount) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("retry_count must be between 0 and %d", maxRetryCount)})
+			return
+		}
+		if req.FailureThreshold != nil && (*req.FailureThreshold < 1 || *req.FailureThreshold > maxFailureThreshold) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("failure_threshold must be between 1 and %d", maxFailureThreshold)})
+			return
+		}
+		if req.CertExpiryThresholdDays != nil && (*req.CertExpiryThresholdDays < 0 || *req.CertExpiryThresholdDays > maxCertExpiryThresholdDays) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("cert_expiry_threshold_days must be between 0 and %d", maxCertExpiryThresholdDays)})
+			return
+		}
+		if err := validateBodyReplacePattern(req.BodyReplacePattern); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		clientProfile, ok := normalizeClientProfile(req.ClientProfile)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "client_profile must be default, browser, or minimal"})
+			return
+		}
+		regionHeader := strings.TrimSpace(req.RegionHeader)
+		expectedRegions := normalizeList(req.ExpectedRegions)
+		if err := validateRegionAssertion(regionHeader, expectedRegions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		healthBoolPath := strings.TrimSpace(req.HealthBoolPath)
+		if healthBoolPath != "" && !validJSONPath(healthBoolPath) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid health_bool_path"})
+			return
+		}
+		if err := validateHeaderAssertions(req.HeaderAssertions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid header_assertions: " + err.Error()})
+			return
+		}
+		xpath := strings.TrimSpace(req.XPath)
+		if xpath != "" {
+			if _, err := compileXPath(xpath); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid xpath: " + err.Error()})
+				return
+			}
+		}
+		sniHostname := strings.TrimSpace(req.SNIHostname)
+		if sniHostname != "" && !validHostname(sniHostname) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid sni_hostname"})
+			return
+		}
+
+		monitor := Monitor{
+			Name:                        name,
+			Type:                        typeValue,
+			URL:                         urlValue,
+			Status:                      statusUnknown,
+			CertPinned:                  req.CertPinned,
+			ChangeThresholdPercent:      req.ChangeThresholdPercent,
+			FeedMaxAgeSeconds:           req.FeedMaxAgeSeconds,
+			FailureMessage:              strings.TrimSpace(req.FailureMessage),
+			HealthBoolPath:              healthBoolPath,
+			HeaderAssertions:            stringMap(req.HeaderAssertions),
+			SNIHostname:                 sniHostname,
+			DBQuery:                     strings.TrimSpace(req.DBQuery),
+			XPath:                       xpath,
+			XPathExpected:               req.XPathExpected,
+			CheckAllIPs:                 req.CheckAllIPs,
+			DebugLogging:                req.DebugLogging,
+			Priority:                    req.Priority,
+			UDPPayload:                  req.UDPPayload,
+			AnomalyZScoreThreshold:      req.AnomalyZScoreThreshold,
+			RegionHeader:                regionHeader,
+			ExpectedRegions:             expectedRegions,
+			RecoveryConfirmationSeconds: req.RecoveryConfirmationSeconds,
+			UserAgents:                  normalizeList(req.UserAgents),
+			ClientProfile:               clientProfile,
+			Public:                      req.Public,
+			IntervalSeconds:             req.IntervalSeconds,
+			MaxJitterMs:                 req.MaxJitterMs,
+			TimeoutSeconds:              req.TimeoutSeconds,
+			DegradedThresholdMs:         req.DegradedThresholdMs,
+			ErrorRateWindow:             req.ErrorRateWindow,
+			ErrorRateDegradedPercent:    req.ErrorRateDegradedPercent,
+			ErrorRateUnhealthyPercent:   req.ErrorRateUnhealthyPercent,
+			RetryCount:                  req.RetryCount,
+			FailureThreshold:            req.FailureThreshold,
+			FallbackURL:                 fallbackURL,
+			BodyStripPrefix:             req.BodyStripPrefix,
+			BodyStripSuffix:             req.BodyStripSuffix,
+			BodyReplacePattern:          req.BodyReplacePattern,
+			BodyReplacement:             req.BodyReplacement,
+			PagerDutyRoutingKey:         strings.TrimSpace(req.PagerDutyRoutingKey),
+			ExpectClosed:                req.ExpectClosed,
+			CertExpiryThresholdDays:     req.CertExpiryThresholdDays,
+			ExpectedKeyword:             req.ExpectedKeyword,
+			ColdTimeoutSeconds:          req.ColdTimeoutSeconds,
+			ColdAfterSeconds:            req.ColdAfterSeconds,
+			ExpectedStatusCodes:         expectedStatusCodes,
+			Method:                      method,
+			RequestBody:                 req.RequestBody,
+			SamplePaths:                 samplePaths,
+			PathSampling:                pathSampling,
+			Headers:                     stringMap(req.Headers),
+			NotificationSchedule:        notificationSchedule,
+			NotificationTimezone:        notificationTimezone,
+			Steps:                       steps,
+			OwnerKey:                    c.GetString(ownerContextKey),
+		}
+
+		if err := checker.validateCheckBudget(&monitor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&monitor).Error; err != nil {
+				return err
+			}
+			if idempotencyKey == "" {
+				return nil
+			}
+			return tx.Create(&IdempotencyKey{Key: idempotencyKey, MonitorID: monitor.ID}).Error
+		})
+		if err != nil {
+			// A concurrent request with the same key may have won the race.
+			if idempotencyKey != "" {
+				if existing, ok := findIdempotentMonitor(db, idempotencyKey, idempotencyWindow); ok {
+					c.Header("Idempotent-Replayed", "true")
+					c.JSON(http.StatusCreated, existing)
+					return
+				}
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create monitor"})
+			return
+		}
+
+		checker.triggerCheck(monitor.ID)
+
+		c.JSON(http.StatusCreated, monitor)
+	})
+
+	router.PUT("/monitor/:id", authorize(keys, false), func(c *gin.Context) {
+		var req monitorUpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+			return
+		}
+
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Name cannot be empty"})
+				return
+			}
+			monitor.Name = name
+		}
+		if req.Type != nil {
+			typeValue := strings.TrimSpace(*req.Type)
+			if typeValue == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Type cannot be empty"})
+				return
+			}
+			if err := validateMonitorType(typeValue); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.Type = typeValue
+		}
+		if req.URL != nil {
+			urlValue := strings.TrimSpace(*req.URL)
+			if urlValue == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "URL cannot be empty"})
+				return
+			}
+			// validateTarget below checks the URL against the monitor's type.
+			if urlValue != monitor.URL {
+				// A new target presents a different certificate; re-learn the baseline.
+				monitor.CertFingerprint = ""
+			}
+			monitor.URL = urlValue
+		}
+		if req.CertPinned != nil {
+			monitor.CertPinned = *req.CertPinned
+		}
+		if req.ChangeThresholdPercent != nil {
+			if !validPercent(*req.ChangeThresholdPercent) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "change_threshold_percent must be between 0 and 100"})
+				return
+			}
+			monitor.ChangeThresholdPercent = *req.ChangeThresholdPercent
+		}
+		if req.FeedMaxAgeSeconds != nil {
+			if *req.FeedMaxAgeSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "feed_max_age_seconds cannot be negative"})
+				return
+			}
+			monitor.FeedMaxAgeSeconds = *req.FeedMaxAgeSeconds
+		}
+		if req.FailureMessage != nil {
+			monitor.FailureMessage = strings.TrimSpace(*req.FailureMessage)
+		}
+		if req.HealthBoolPath != nil {
+			healthBoolPath := strings.TrimSpace(*req.HealthBoolPath)
+			if healthBoolPath != "" && !validJSONPath(healthBoolPath) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid health_bool_path"})
+				return
+			}
+			monitor.HealthBoolPath = healthBoolPath
+		}
+		if req.HeaderAssertions != nil {
+			if err := validateHeaderAssertions(*req.HeaderAssertions); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid header_assertions: " + err.Error()})
+				return
+			}
+			monitor.HeaderAssertions = stringMap(*req.HeaderAssertions)
+		}
+		if req.SNIHostname != nil {
+			sniHostname := strings.TrimSpace(*req.SNIHostname)
+			if sniHostname != "" && !validHostname(sniHostname) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid sni_hostname"})
+				return
+			}
+			monitor.SNIHostname = sniHostname
+		}
+		if req.DBQuery != nil {
+			monitor.DBQuery = strings.TrimSpace(*req.DBQuery)
+		}
+		if req.XPath != nil {
+			xpath := strings.TrimSpace(*req.XPath)
+			if xpath != "" {
+				if _, err := compileXPath(xpath); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid xpath: " + err.Error()})
+					return
+				}
+			}
+			monitor.XPath = xpath
+		}
+		if req.XPathExpected != nil {
+			monitor.XPathExpected = *req.XPathExpected
+		}
+		if req.CheckAllIPs != nil {
+			monitor.CheckAllIPs = *req.CheckAllIPs
+			if !monitor.CheckAllIPs {
+				monitor.IPResults = nil
+			}
+		}
+		if req.DebugLogging != nil {
+			monitor.DebugLogging = *req.DebugLogging
+		}
+		if req.Priority != nil {
+			if *req.Priority < 0 || *req.Priority > maxMonitorPriority {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "priority must be between 0 and 10"})
+				return
+			}
+			monitor.Priority = *req.Priority
+		}
+		if req.UDPPayload != nil {
+			if _, err := decodeUDPPayload(*req.UDPPayload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid udp_payload"})
+				return
+			}
+			monitor.UDPPayload = *req.UDPPayload
+		}
+		if req.AnomalyZScoreThreshold != nil {
+			if *req.AnomalyZScoreThreshold < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "anomaly_z_score_threshold cannot be negative"})
+				return
+			}
+			monitor.AnomalyZScoreThreshold = *req.AnomalyZScoreThreshold
+		}
+		if req.RecoveryConfirmationSeconds != nil {
+			if *req.RecoveryConfirmationSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "recovery_confirmation_seconds cannot be negative"})
+				return
+			}
+			monitor.RecoveryConfirmationSeconds = *req.RecoveryConfirmationSeconds
+		}
+		if req.UserAgents != nil {
+			monitor.UserAgents = normalizeList(*req.UserAgents)
+		}
+		if req.Public != nil {
+			monitor.Public = *req.Public
+		}
+		if req.IntervalSeconds != nil {
+			if *req.IntervalSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "interval_seconds cannot be negative"})
+				return
+			}
+			monitor.IntervalSeconds = *req.IntervalSeconds
+		}
+		if req.MaxJitterMs != nil {
+			if *req.MaxJitterMs < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "max_jitter_ms cannot be negative"})
+				return
+			}
+			monitor.MaxJitterMs = *req.MaxJitterMs
+		}
+		if req.TimeoutSeconds != nil {
+			if *req.TimeoutSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "timeout_seconds cannot be negative"})
+				return
+			}
+			monitor.TimeoutSeconds = *req.TimeoutSeconds
+		}
+		if req.ColdTimeoutSeconds != nil {
+			if *req.ColdTimeoutSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "cold_timeout_seconds and cold_after_seconds cannot be negative"})
+				return
+			}
+			monitor.ColdTimeoutSeconds = *req.ColdTimeoutSeconds
+		}
+		if req.ColdAfterSeconds != nil {
+			if *req.ColdAfterSeconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "cold_timeout_seconds and cold_after_seconds cannot be negative"})
+				return
+			}
+			monitor.ColdAfterSeconds = *req.ColdAfterSeconds
+		}
+		if req.ExpectedStatusCodes != nil {
+			expectedStatusCodes, err := normalizeStatusCodes(*req.ExpectedStatusCodes)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.ExpectedStatusCodes = expectedStatusCodes
+		}
+		if req.Method != nil {
+			method, err := normalizeMethod(*req.Method)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.Method = method
+		}
+		if req.RequestBody != nil {
+			monitor.RequestBody = *req.RequestBody
+		}
+		if err := validateRequestBody(monitor.requestMethod(), monitor.RequestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if req.SamplePaths != nil {
+			monitor.SamplePaths = normalizeList(*req.SamplePaths)
+			// Statuses of paths no longer sampled must not hold the rollup.
+			monitor.PathStatuses = nil
+		}
+		if req.PathSampling != nil {
+			monitor.PathSampling = strings.ToLower(strings.TrimSpace(*req.PathSampling))
+		}
+		if err := validateSamplePaths(monitor.SamplePaths, monitor.PathSampling); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if req.Headers != nil {
+			if err := validateRequestHeaders(*req.Headers); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.Headers = stringMap(*req.Headers)
+		}
+		if req.NotificationTimezone != nil {
+			monitor.NotificationTimezone = strings.TrimSpace(*req.NotificationTimezone)
+		}
+		schedule := []severityWindow(monitor.NotificationSchedule)
+		if req.NotificationSchedule != nil {
+			schedule = *req.NotificationSchedule
+		}
+		notificationSchedule, err := normalizeSeveritySchedule(schedule, monitor.NotificationTimezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		monitor.NotificationSchedule = notificationSchedule
+		if req.Steps != nil || req.Type != nil {
+			steps := []transactionStep(monitor.Steps)
+			if req.Steps != nil {
+				steps = *req.Steps
+			}
+			normalized, err := normalizeSteps(monitor.Type, steps)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.Steps = normalized
+		}
+		if req.DegradedThresholdMs != nil {
+			if *req.DegradedThresholdMs < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "degraded_threshold_ms cannot be negative"})
+				return
+			}
+			monitor.DegradedThresholdMs = *req.DegradedThresholdMs
+		}
+		if req.ErrorRateWindow != nil {
+			if *req.ErrorRateWindow < 0 || *req.ErrorRateWindow > maxErrorRateWindow {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("error_rate_window must be between 0 and %d", maxErrorRateWindow)})
+				return
+			}
+			if *req.ErrorRateWindow == 0 {
+				monitor.RecentOutcomes = ""
+				monitor.LastErrorRate = 0
+			}
+			monitor.ErrorRateWindow = *req.ErrorRateWindow
+		}
+		if req.ErrorRateDegradedPercent != nil {
+			if !validPercent(*req.ErrorRateDegradedPercent) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "error rate thresholds must be between 0 and 100"})
+				return
+			}
+			monitor.ErrorRateDegradedPercent = *req.ErrorRateDegradedPercent
+		}
+		if req.ErrorRateUnhealthyPercent != nil {
+			if !validPercent(*req.ErrorRateUnhealthyPercent) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "error rate thresholds must be between 0 and 100"})
+				return
+			}
+			monitor.ErrorRateUnhealthyPercent = *req.ErrorRateUnhealthyPercent
+		}
+		if req.RetryCount != nil {
+			if *req.RetryCount < 0 || *req.RetryCount > maxRetryCount {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("retry_count must be between 0 and %d", maxRetryCount)})
+				return
+			}
+			monitor.RetryCount = req.RetryCount
+		}
+		if req.FailureThreshold != nil {
+			if *req.FailureThreshold < 1 || *req.FailureThreshold > maxFailureThreshold {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("failure_threshold must be between 1 and %d", maxFailureThreshold)})
+				return
+			}
+			monitor.FailureThreshold = req.FailureThreshold
+		}
+		if req.BodyStripPrefix != nil {
+			monitor.BodyStripPrefix = *req.BodyStripPrefix
+		}
+		if req.BodyStripSuffix != nil {
+			monitor.BodyStripSuffix = *req.BodyStripSuffix
+		}
+		if req.BodyReplacePattern != nil {
+			if err := validateBodyReplacePattern(*req.BodyReplacePattern); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			monitor.BodyReplacePattern = *req.BodyReplacePattern
+		}
+		if req.BodyReplacement != nil {
+			monitor.BodyReplacement = *req.BodyReplacement
+		}
+		if req.PagerDutyRoutingKey != nil {
+			monitor.PagerDutyRoutingKey = strings.TrimSpace(*req.PagerDutyRoutingKey)
+		}
+		if req.ExpectClosed != nil {
+			monitor.ExpectClosed = *req.ExpectClosed
+		}
+		if req.CertExpiryThresholdDays != nil {
+			if *req.CertExpiryThresholdDays < 0 || *req.CertExpiryThresholdDays > maxCertExpiryThresholdDays {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("cert_expiry_threshold_days must be between 0 and %d", maxCertExpiryThresholdDays)})
+				return
+			}
+			monitor.CertExpiryThresholdDays = req.CertExpiryThresholdDays
+		}
+		if req.ExpectedKeyword != nil {
+			monitor.ExpectedKeyword = *req.ExpectedKeyword
+		}
+		if req.ClientProfile != nil {
+			clientProfile, ok := normalizeClientProfile(*req.ClientProfile)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "client_profile must be default, browser, or minimal"})
+				return
+			}
+			monitor.ClientProfile = clientProfile
+		}
+		if req.RegionHeader != nil || req.ExpectedRegions != nil {
+			if req.RegionHeader != nil {
+				monitor.RegionHeader = strings.TrimSpace(*req.RegionHeader)
+			}
+			if req.ExpectedRegions != nil {
+				monitor.ExpectedRegions = normalizeList(*req.ExpectedRegions)
+			}
+			if err := validateRegionAssertion(monitor.RegionHeader, monitor.ExpectedRegions); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+		}
+		if req.Type != nil || req.URL != nil {
+			if err := validateTarget(monitor.Type, monitor.URL); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid URL"})
+				return
+			}
+		}
+		if req.FallbackURL != nil {
+			monitor.FallbackURL = strings.TrimSpace(*req.FallbackURL)
+			if monitor.FallbackURL == "" {
+				monitor.LastEndpoint = ""
+			}
+		}
+		if req.Type != nil || req.FallbackURL != nil {
+			if err := validateFallbackURL(monitor.Type, monitor.FallbackURL); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+		}
+		if req.TimeoutSeconds != nil || req.ColdTimeoutSeconds != nil || req.RetryCount != nil || req.FallbackURL != nil {
+			if err := checker.validateCheckBudget(&monitor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+		}
+
+		if err := db.Save(&monitor).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update monitor"})
 			return
 		}
-		mc.checkMonitor(context.Background(), &monitor)
-	}()
-}
-
-func (mc *monitorChecker) checkMonitor(ctx context.Context, monitor *Monitor) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, monitor.URL, nil)
-	if err != nil {
-		log.Printf("failed to build request for monitor %d: %v", monitor.ID, err)
-		return
-	}
-	start := time.Now()
-	status := statusUnhealthy
-	code := 0
-	latency := 0
-	resp, err := mc.client.Do(req)
-	if err != nil {
-		log.Printf("monitor %d request failed: %v", monitor.ID, err)
-	} else {
-		code = resp.StatusCode
-		latency = int(time.Since(start) / time.Millisecond)
-		resp.Body.Close()
-		status = deriveStatusFromCode(code)
-	}
-	update := map[string]interface{}{
-		"status":                status,
-		"last_check":            time.Now(),
-		"last_response_code":    code,
-		"last_response_time_ms": latency,
-	}
-	if err := mc.db.Model(&Monitor{}).Where("id = ?", monitor.ID).Updates(update).Error; err != nil {
-		log.Printf("monitor %d update failed: %v", monitor.ID, err)
-	}
-}
-
-func deriveStatusFromCode(code int) string {
-	switch {
-	case code >= 200 && code < 400:
-		return statusHealthy
-	case code >= 400 && code < 500:
-		return statusDegraded
-	case code == 0:
-		return statusUnhealthy
-	default:
-		return statusUnhealthy
-	}
-}
-
-func main() {
-	_ = godotenv.Load()
-
-	readKey := strings.TrimSpace(getEnv("READ_KEY"))
-	adminKey := strings.TrimSpace(getEnv("ADMIN_KEY"))
-
-	if readKey == "" || adminKey == "" {
-		log.Fatal("READ_KEY and ADMIN_KEY must be provided via environment variables")
-	}
 
-	db, err := gorm.Open(sqlite.Open("monitors.db"), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
-	}
-
-	if err := db.AutoMigrate(&Monitor{}); err != nil {
-		log.Fatalf("failed to migrate database: %v", err)
-	}
-
-	checker := newMonitorChecker(db)
-	interval := time.Duration(getEnvAsInt("CHECK_INTERVAL_SECONDS", 30)) * time.Second
-	checker.start(context.Background(), interval)
+		checker.triggerCheck(monitor.ID)
 
-	router := gin.Default()
+		c.JSON(http.StatusOK, monitor)
+	})
 
-	router.GET("/monitor", authorize(readKey, adminKey, true), func(c *gin.Context) {
-		var monitors []Monitor
-		if err := db.Order("id asc").Find(&monitors).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch monitors"})
+	router.POST("/monitor/:id/certificate/accept", authorize(keys, false), func(c *gin.Context) {
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+			return
+		}
+		if monitor.LastCertFingerprint == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "No certificate observed yet"})
 			return
 		}
-		c.JSON(http.StatusOK, monitors)
-	})
 
-	router.POST("/monitor", authorize(readKey, adminKey, false), func(c *gin.Context) {
-		var req monitorCreateRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+		monitor.CertFingerprint = monitor.LastCertFingerprint
+		if err := db.Model(&monitor).Update("cert_fingerprint", monitor.CertFingerprint).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update monitor"})
 			return
 		}
 
-		name := strings.TrimSpace(req.Name)
-		typeValue := strings.TrimSpace(req.Type)
-		urlValue := strings.TrimSpace(req.URL)
-		if name == "" || typeValue == "" || urlValue == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Name, type, and url are required"})
+		checker.triggerCheck(monitor.ID)
+
+		c.JSON(http.StatusOK, monitor)
+	})
+
+	// Pausing stops scheduled and triggered checks; the monitor keeps its last
+	// status but no longer counts towards /status or group rollups.
+	router.POST("/monitor/:id/pause", authorize(keys, false), func(c *gin.Context) {
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
 			return
 		}
-		if err := validateURL(urlValue); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid URL"})
+		if err := db.Model(&monitor).Update("enabled", false).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update monitor"})
 			return
 		}
+		c.JSON(http.StatusOK, monitor)
+	})
 
-		monitor := Monitor{
-			Name:   name,
-			Type:   typeValue,
-			URL:    urlValue,
-			Status: statusUnknown,
+	router.POST("/monitor/:id/resume", authorize(keys, false), func(c *gin.Context) {
+		var monitor Monitor
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
+			return
 		}
-
-		if err := db.Create(&monitor).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create monitor"})
+		if err := db.Model(&monitor).Update("enabled", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update monitor"})
 			return
 		}
 
 		checker.triggerCheck(monitor.ID)
 
-		c.JSON(http.StatusCreated, monitor)
+		c.JSON(http.StatusOK, monitor)
 	})
 
-	router.PUT("/monitor/:id", authorize(readKey, adminKey, false), func(c *gin.Context) {
-		var req monitorUpdateRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
-			return
-		}
-
+	// Runs a check synchronously. The check uses the caller's request context,
+	// so a client that disconnects or times out cancels the outbound probe.
+	router.POST("/monitor/:id/check", authorize(keys, false), func(c *gin.Context) {
 		var monitor Monitor
-		if err := db.First(&monitor, c.Param("id")).Error; err != nil {
+		if err := ownedMonitors(c, db).First(&monitor, c.Param("id")).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Monitor not found"})
 			return
 		}
-
-		if req.Name != nil {
-			name := strings.TrimSpace(*req.Name)
-			if name == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Name cannot be empty"})
-				return
-			}
-			monitor.Name = name
-		}
-		if req.Type != nil {
-			typeValue := strings.TrimSpace(*req.Type)
-			if typeValue == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Type cannot be empty"})
-				return
-			}
-			monitor.Type = typeValue
-		}
-		if req.URL != nil {
-			urlValue := strings.TrimSpace(*req.URL)
-			if urlValue == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "URL cannot be empty"})
-				return
-			}
-			if err := validateURL(urlValue); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid URL"})
-				return
-			}
-			monitor.URL = urlValue
+		if !monitor.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"message": "Monitor is paused"})
+			return
 		}
 
-		if err := db.Save(&monitor).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update monitor"})
+		ctx := c.Request.Context()
+		checker.checkMonitor(ctx, &monitor)
+		if ctx.Err() != nil {
+			slog.Warn("synchronous check canceled by client", "monitor_id", monitor.ID, "error", ctx.Err())
 			return
 		}
 
-		checker.triggerCheck(monitor.ID)
-
+		if err := db.First(&monitor, monitor.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch monitor"})
+			return
+		}
 		c.JSON(http.StatusOK, monitor)
 	})
 
-	router.DELETE("/monitor/:id", authorize(readKey, adminKey, false), func(c *gin.Context) {
-		if err := db.Delete(&Monitor{}, c.Param("id")).Error; err != nil {
+	router.DELETE("/monitor/:id", authorize(keys, false), func(c *gin.Context) {
+		result := ownedMonitors(c, db).Delete(&Monitor{}, c.Param("id"))
+		if result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete monitor"})
 			return
 		}
+		if result.RowsAffected > 0 {
+			if err := db.Where("monitor_id = ?", c.Param("id")).Delete(&MonitorGroupMember{}).Error; err != nil {
+				slog.Error("failed to remove monitor from groups", "monitor_id", c.Param("id"), "error", err)
+			}
+			if err := db.Where("monitor_id = ?", c.Param("id")).Delete(&CheckResult{}).Error; err != nil {
+				slog.Error("failed to delete monitor history", "monitor_id", c.Param("id"), "error", err)
+			}
+			if id, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil {
+				checker.metrics.forget(uint(id))
+			}
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Monitor deleted"})
 	})
 
-	router.GET("/status", authorize(readKey, adminKey, true), func(c *gin.Context) {
+	registerTemplateRoutes(router, db, checker, keys)
+	registerOpenAPIRoutes(router, db, checker, keys)
+	registerIngestRoutes(router, db, checker, keys)
+	registerGroupRoutes(router, db, keys)
+	registerHistoryRoutes(router, db, keys)
+	registerBandwidthRoutes(router, db, keys)
+	if checker.metrics != nil {
+		registerMetricsRoutes(router, checker.metrics)
+	}
+	statusPageRefresh := getEnvAsInt("STATUS_PAGE_REFRESH_SECONDS", 60)
+	if statusPageRefresh <= 0 {
+		statusPageRefresh = 60
+	}
+	statusPageTitle := strings.TrimSpace(getEnv("STATUS_PAGE_TITLE"))
+	if statusPageTitle == "" {
+		statusPageTitle = "Service Status"
+	}
+	registerStatusPageRoutes(router, db, keys, statusPageTitle, statusPageRefresh)
+
+	router.GET("/status", authorize(keys, true), func(c *gin.Context) {
 		var monitors []Monitor
-		if err := db.Find(&monitors).Error; err != nil {
+		if err := ownedMonitors(c, db).Where("enabled = ?", true).Find(&monitors).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch status"})
 			return
 		}
 
 		healthy := 0
-		degraded := 0
-		unknown := 0
-		for _, m := range monitors {
-			switch strings.ToUpper(m.Status) {
-			case statusHealthy:
+		statuses := make([]string, len(monitors))
+		for i, m := range monitors {
+			statuses[i] = strings.ToUpper(m.Status)
+			if statuses[i] == statusHealthy {
 				healthy++
-			case statusDegraded:
-				degraded++
-			case statusUnknown:
-				unknown++
-			}
-		}
-
-		statusValue := statusUnknown
-		if len(monitors) == 0 {
-			statusValue = statusUnknown
-		} else if healthy == len(monitors) {
-			statusValue = statusHealthy
-		} else if healthy == 0 && degraded == 0 && unknown == len(monitors) {
-			statusValue = statusUnknown
-		} else if healthy == 0 && degraded == 0 {
-			statusValue = statusUnhealthy
-		} else {
-			statusValue = statusDegraded
+			}
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"status":           statusValue,
+			"status":           rollupStatus(statuses),
 			"monitors":         len(monitors),
 			"healthy_monitors": healthy,
 		})
 	})
 
-	if err := router.Run(); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	router.GET("/status/score", authorize(keys, true), func(c *gin.Context) {
+		var monitors []Monitor
+		if err := ownedMonitors(c, db).Where("enabled = ?", true).Find(&monitors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to compute health score"})
+			return
+		}
+		c.JSON(http.StatusOK, computeHealthScore(monitors, scoreWeights))
+	})
+
+	server := &http.Server{Addr: listenAddress(), Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down: finishing in-flight requests and checks")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), checker.maxDuration+shutdownGrace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown incomplete", "error", err)
+	}
+	if err := checker.wait(shutdownCtx); err != nil {
+		slog.Error("gave up waiting for in-flight checks", "error", err)
+	}
+	stopPublisher()
+}
+
+// listenAddress is LISTEN_ADDR when set. Otherwise it mirrors gin's default:
+// PORT when set, otherwise :8080.
+func listenAddress() string {
+	if addr := strings.TrimSpace(getEnv("LISTEN_ADDR")); addr != "" {
+		return addr
+	}
+	if port := strings.TrimSpace(getEnv("PORT")); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+// Context keys set by authorize for downstream handlers.
+const (
+	ownerContextKey      = "owner"
+	superadminContextKey = "superadmin"
+	publicContextKey     = "public"
+)
+
+// apiKeys maps every configured key to the owner whose monitors it may access.
+// Owners are identified by the hash of their admin key.
+type apiKeys struct {
+	read         map[string]string
+	admin        map[string]string
+	superadmin   string
+	defaultOwner string
+	// public keys read the public monitors of every owner and nothing else.
+	public map[string]bool
+}
+
+// newAPIKeys parses comma-separated READ_KEY and ADMIN_KEY lists. The read key
+// at each position belongs to the same owner as the admin key at that position.
+// PUBLIC_READ_KEY keys belong to no owner.
+func newAPIKeys(readList, adminList, superadmin, publicList string) (apiKeys, error) {
+	readKeys := splitList(readList)
+	adminKeys := splitList(adminList)
+	if len(adminKeys) == 0 {
+		return apiKeys{}, fmt.Errorf("ADMIN_KEY has no keys")
 	}
+	if len(readKeys) != len(adminKeys) {
+		return apiKeys{}, fmt.Errorf("READ_KEY has %d keys but ADMIN_KEY has %d", len(readKeys), len(adminKeys))
+	}
+	keys := apiKeys{
+		read:       make(map[string]string, len(readKeys)),
+		admin:      make(map[string]string, len(adminKeys)),
+		superadmin: superadmin,
+	}
+	for i, adminKey := range adminKeys {
+		owner := hashKey(adminKey)
+		keys.admin[adminKey] = owner
+		keys.read[readKeys[i]] = owner
+	}
+	keys.defaultOwner = hashKey(adminKeys[0])
+	keys.public = make(map[string]bool)
+	for _, publicKey := range splitList(publicList) {
+		if _, ok := keys.admin[publicKey]; ok || keys.read[publicKey] != "" || publicKey == superadmin {
+			return apiKeys{}, fmt.Errorf("a PUBLIC_READ_KEY key is also a read or admin key")
+		}
+		keys.public[publicKey] = true
+	}
+	return keys, nil
 }
 
 // authorize returns middleware enforcing key-based access control.
-func authorize(readKey, adminKey string, allowRead bool) gin.HandlerFunc {
+func authorize(keys apiKeys, allowRead bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		key := strings.TrimSpace(c.GetHeader("Authorization"))
 		if key == "" {
@@ -331,12 +2420,27 @@ func authorize(readKey, adminKey string, allowRead bool) gin.HandlerFunc {
 			return
 		}
 
-		if key == adminKey {
+		if keys.superadmin != "" && key == keys.superadmin {
+			c.Set(ownerContextKey, hashKey(key))
+			c.Set(superadminContextKey, true)
+			c.Next()
+			return
+		}
+
+		if owner, ok := keys.admin[key]; ok {
+			c.Set(ownerContextKey, owner)
+			c.Next()
+			return
+		}
+
+		if owner, ok := keys.read[key]; ok && allowRead {
+			c.Set(ownerContextKey, owner)
 			c.Next()
 			return
 		}
 
-		if allowRead && key == readKey {
+		if keys.public[key] && allowRead {
+			c.Set(publicContextKey, true)
 			c.Next()
 			return
 		}
@@ -346,6 +2450,44 @@ func authorize(readKey, adminKey string, allowRead bool) gin.HandlerFunc {
 	}
 }
 
+// ownedMonitors scopes a monitor query to the caller's monitors. The
+// superadmin key sees every monitor, and a public key every public one.
+func ownedMonitors(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if c.GetBool(superadminContextKey) {
+		return db.Model(&Monitor{})
+	}
+	if c.GetBool(publicContextKey) {
+		return db.Model(&Monitor{}).Where("public = ?", true)
+	}
+	return db.Model(&Monitor{}).Where("owner_key = ?", c.GetString(ownerContextKey))
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// findIdempotentMonitor returns the monitor previously created for key if the
+// key was recorded within window. Expired keys are pruned along the way.
+func findIdempotentMonitor(db *gorm.DB, key string, window time.Duration) (Monitor, bool) {
+	var monitor Monitor
+	cutoff := time.Now().Add(-window)
+	if err := db.Where("created_at < ?", cutoff).Delete(&IdempotencyKey{}).Error; err != nil {
+		slog.Error("idempotency key cleanup failed", "error", err)
+	}
+
+	var record IdempotencyKey
+	if err := db.Where("key = ?", key).First(&record).Error; err != nil {
+		return monitor, false
+	}
+	if err := db.First(&monitor, record.MonitorID).Error; err != nil {
+		// The monitor was deleted since; let the key be reused.
+		db.Delete(&record)
+		return monitor, false
+	}
+	return monitor, true
+}
+
 // getEnv wraps lookup to simplify testing and defaults.
 func getEnv(key string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -365,7 +2507,152 @@ func getEnvAsInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvAsFloat(key string, fallback float64) float64 {
+	value := strings.TrimSpace(getEnv(key))
+	if value == "" {
+		return fallback
+	}
+	if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+		return parsed
+	}
+	return fallback
+}
+
+// getEnvAsDuration accepts a Go duration such as "45s" or a plain number of
+// seconds.
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	value := strings.TrimSpace(getEnv(key))
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if parsed, err := time.ParseDuration(value); err == nil {
+		return parsed
+	}
+	return fallback
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	value := strings.TrimSpace(getEnv(key))
+	if value == "" {
+		return fallback
+	}
+	if parsed, err := strconv.ParseBool(value); err == nil {
+		return parsed
+	}
+	return fallback
+}
+
+// HTTP monitor types. Both request the url as given, whatever its scheme.
+const (
+	monitorTypeHTTP  = "http"
+	monitorTypeHTTPS = "https"
+)
+
+// monitorTypes lists every type a monitor may have, in the order error
+// messages name them. A new kind of check adds its type here.
+var monitorTypes = []string{
+	monitorTypeHTTP,
+	monitorTypeHTTPS,
+	monitorTypeTCP,
+	monitorTypeUDP,
+	monitorTypePing,
+	monitorTypeDB,
+	monitorTypeFeed,
+	monitorTypeTransaction,
+}
+
+// validateMonitorType accepts the types in monitorTypes, in any case.
+func validateMonitorType(typeValue string) error {
+	for _, known := range monitorTypes {
+		if strings.EqualFold(typeValue, known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("type must be one of %s", strings.Join(monitorTypes, ", "))
+}
+
+// normalizeMonitorTypes rewrites types stored before they were validated.
+// Known types are lowercased; any other value, often a region label from the
+// dashboard, becomes http or https by the URL's scheme, since such monitors
+// were always checked over HTTP.
+func normalizeMonitorTypes(db *gorm.DB) error {
+	for _, known := range monitorTypes {
+		if err := db.Model(&Monitor{}).Where("LOWER(TRIM(type)) = ? AND type <> ?", known, known).UpdateColumn("type", known).Error; err != nil {
+			return err
+		}
+	}
+	var migrated int64
+	for _, scheme := range []struct{ typeValue, pattern string }{
+		{monitorTypeHTTPS, "https:%"},
+		{monitorTypeHTTP, "%"},
+	} {
+		result := db.Model(&Monitor{}).
+			Where("(type IS NULL OR LOWER(TRIM(type)) NOT IN ?) AND LOWER(url) LIKE ?", monitorTypes, scheme.pattern).
+			UpdateColumn("type", scheme.typeValue)
+		if result.Error != nil {
+			return result.Error
+		}
+		migrated += result.RowsAffected
+	}
+	if migrated > 0 {
+		slog.Info("gave legacy monitor types an HTTP type", "count", migrated)
+	}
+	return nil
+}
+
+// validateTarget applies type-specific checks to a monitor's URL before the
+// generic URL validation.
+func validateTarget(typeValue, raw string) error {
+	if strings.EqualFold(typeValue, monitorTypePing) {
+		return validPingHost(raw)
+	}
+	if strings.EqualFold(typeValue, monitorTypeDB) {
+		if _, _, err := databaseDSN(raw); err != nil {
+			return err
+		}
+	}
+	if strings.EqualFold(typeValue, monitorTypeUDP) || strings.EqualFold(typeValue, monitorTypeTCP) {
+		if _, err := socketAddress(raw, strings.ToLower(typeValue)); err != nil {
+			return err
+		}
+	}
+	return validateURL(raw)
+}
+
 func validateURL(raw string) error {
 	_, err := url.ParseRequestURI(raw)
 	return err
 }
+
+func validPercent(value float64) bool {
+	return value >= 0 && value <= 100
+}
+
+func splitList(raw string) []string {
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if value := strings.TrimSpace(part); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+var hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// validHostname reports whether name is a DNS hostname (not an IP address).
+func validHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 || net.ParseIP(name) != nil {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
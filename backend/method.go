@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxRequestBodyBytes bounds the request_body sent with each check.
+const maxRequestBodyBytes = 64 << 10
+
+// checkMethods are the HTTP methods a monitor may probe with.
+var checkMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// normalizeMethod validates a monitor's method and returns it upper-cased;
+// an empty value means GET.
+func normalizeMethod(raw string) (string, error) {
+	method := strings.ToUpper(strings.TrimSpace(raw))
+	if method == "" {
+		return http.MethodGet, nil
+	}
+	for _, allowed := range checkMethods {
+		if method == allowed {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("method must be one of %s", strings.Join(checkMethods, ", "))
+}
+
+// validateRequestBody checks that body may be sent with method.
+func validateRequestBody(method, body string) error {
+	if body == "" {
+		return nil
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return fmt.Errorf("request_body cannot be sent with %s", method)
+	}
+	if len(body) > maxRequestBodyBytes {
+		return fmt.Errorf("request_body cannot exceed %d bytes", maxRequestBodyBytes)
+	}
+	return nil
+}
+
+// requestMethod is the method checks of the monitor use.
+func (m *Monitor) requestMethod() string {
+	if m.Method == "" {
+		return http.MethodGet
+	}
+	return m.Method
+}
+
+// newCheckRequest builds the request for one HTTP check: the monitor's
+// method, and its request_body labelled as JSON when it parses as JSON and
+// as plain text otherwise.
+func newCheckRequest(ctx context.Context, monitor *Monitor) (*http.Request, error) {
+	var body io.Reader
+	if monitor.RequestBody != "" {
+		body = strings.NewReader(monitor.RequestBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, monitor.requestMethod(), monitor.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if monitor.RequestBody != "" {
+		contentType := "text/plain; charset=utf-8"
+		if json.Valid([]byte(monitor.RequestBody)) {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
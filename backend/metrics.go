@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// checkMetrics exposes the results of the checks this instance runs in the
+// Prometheus format. Series are labelled with the monitor's id and name; a
+// renamed or deleted monitor's old series are removed. A nil checkMetrics
+// does nothing.
+type checkMetrics struct {
+	registry      *prometheus.Registry
+	up            *prometheus.GaugeVec
+	responseTime  *prometheus.GaugeVec
+	scheduleLag   *prometheus.GaugeVec
+	checks        *prometheus.CounterVec
+	failures      *prometheus.CounterVec
+	bytesSent     *prometheus.CounterVec
+	bytesReceived *prometheus.CounterVec
+
+	mu    sync.Mutex
+	names map[uint]string
+}
+
+func newCheckMetrics() *checkMetrics {
+	labels := []string{"id", "name"}
+	m := &checkMetrics{
+		registry: prometheus.NewRegistry(),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "monitor_up",
+			Help: "1 when the latest check of the monitor was not UNHEALTHY, 0 when it was.",
+		}, labels),
+		responseTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "monitor_response_time_ms",
+			Help: "Response time of the latest check of the monitor in milliseconds.",
+		}, labels),
+		scheduleLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "monitor_schedule_lag_ms",
+			Help: "How late the latest scheduled check of the monitor started, in milliseconds.",
+		}, labels),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_checks_total",
+			Help: "Checks of the monitor run by this instance.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_failed_checks_total",
+			Help: "Checks of the monitor run by this instance that were UNHEALTHY.",
+		}, labels),
+		bytesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_bytes_sent_total",
+			Help: "Estimated bytes sent by checks of the monitor run by this instance.",
+		}, labels),
+		bytesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_bytes_received_total",
+			Help: "Estimated bytes received by checks of the monitor run by this instance.",
+		}, labels),
+		names: map[uint]string{},
+	}
+	m.registry.MustRegister(
+		m.up, m.responseTime, m.scheduleLag, m.checks, m.failures, m.bytesSent, m.bytesReceived,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observe records a completed check. Cancelled checks, which have no
+// status, are not counted.
+func (m *checkMetrics) observe(monitor *Monitor, update map[string]interface{}) {
+	if m == nil {
+		return
+	}
+	status, ok := update["status"].(string)
+	if !ok {
+		return
+	}
+	id := strconv.FormatUint(uint64(monitor.ID), 10)
+	m.mu.Lock()
+	if name, seen := m.names[monitor.ID]; seen && name != monitor.Name {
+		m.deleteSeries(id)
+	}
+	m.names[monitor.ID] = monitor.Name
+	m.mu.Unlock()
+
+	labels := prometheus.Labels{"id": id, "name": monitor.Name}
+	up := 1.0
+	if status == statusUnhealthy {
+		up = 0
+		m.failures.With(labels).Inc()
+	}
+	m.up.With(labels).Set(up)
+	m.responseTime.With(labels).Set(float64(intValue(update["last_response_time_ms"])))
+	if lag, ok := update["last_schedule_lag_ms"].(int); ok {
+		m.scheduleLag.With(labels).Set(float64(lag))
+	}
+	m.checks.With(labels).Inc()
+	m.bytesSent.With(labels).Add(float64(intValue(update["last_bytes_sent"])))
+	m.bytesReceived.With(labels).Add(float64(intValue(update["last_bytes_received"])))
+}
+
+// forget removes the series of a deleted monitor.
+func (m *checkMetrics) forget(monitorID uint) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteSeries(strconv.FormatUint(uint64(monitorID), 10))
+	delete(m.names, monitorID)
+}
+
+func (m *checkMetrics) deleteSeries(id string) {
+	labels := prometheus.Labels{"id": id}
+	m.up.DeletePartialMatch(labels)
+	m.responseTime.DeletePartialMatch(labels)
+	m.scheduleLag.DeletePartialMatch(labels)
+	m.checks.DeletePartialMatch(labels)
+	m.failures.DeletePartialMatch(labels)
+	m.bytesSent.DeletePartialMatch(labels)
+	m.bytesReceived.DeletePartialMatch(labels)
+}
+
+// registerMetricsRoutes serves GET /metrics without a key.
+func registerMetricsRoutes(router *gin.Engine, metrics *checkMetrics) {
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})))
+}
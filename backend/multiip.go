@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxCheckedIPs bounds how many resolved addresses a single check probes.
+const maxCheckedIPs = 16
+
+// ipResult is the outcome of probing one resolved address of a monitor.
+type ipResult struct {
+	IP             string `json:"ip"`
+	Status         string `json:"status"`
+	ResponseCode   int    `json:"response_code"`
+	ResponseTimeMs int    `json:"response_time_ms"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ipResultList is persisted as a JSON text column.
+type ipResultList []ipResult
+
+func (l ipResultList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(l)
+	return string(encoded), err
+}
+
+func (l *ipResultList) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported ipResultList value %T", value)
+	}
+	if len(raw) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(raw, l)
+}
+
+func (ipResultList) GormDataType() string {
+	return "text"
+}
+
+// checkAllIPs resolves the monitor's host and probes every address in
+// parallel, keeping the original Host header and SNI. The monitor is HEALTHY
+// only when every address is healthy, UNHEALTHY when none are, and DEGRADED
+// when some addresses fail.
+func (mc *monitorChecker) checkAllIPs(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	parsed, err := url.Parse(monitor.URL)
+	if err != nil {
+		slog.Error("invalid URL", "monitor_id", monitor.ID, "error", err)
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addrs) == 0 {
+		slog.Error("resolution failed", "monitor_id", monitor.ID, "error", err)
+		return map[string]interface{}{
+			"status":                statusUnhealthy,
+			"last_check":            time.Now(),
+			"last_response_code":    0,
+			"last_response_time_ms": 0,
+			"last_error":            fmt.Sprintf("resolve %s: %v", parsed.Hostname(), err),
+			"last_error_category":   errorCategoryDNS,
+			"ip_results":            ipResultList(nil),
+		}
+	}
+	if len(addrs) > maxCheckedIPs {
+		addrs = addrs[:maxCheckedIPs]
+	}
+
+	updates := make([]map[string]interface{}, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			updates[i] = mc.checkHTTP(ctx, monitor, ip)
+		}(i, addr.IP.String())
+	}
+	wg.Wait()
+
+	results := make(ipResultList, 0, len(addrs))
+	var base map[string]interface{}
+	healthy, sent, received := 0, 0, 0
+	for i, update := range updates {
+		if update == nil {
+			continue
+		}
+		sent += intValue(update["last_bytes_sent"])
+		received += intValue(update["last_bytes_received"])
+		result := ipResult{
+			IP:             addrs[i].IP.String(),
+			Status:         update["status"].(string),
+			ResponseCode:   update["last_response_code"].(int),
+			ResponseTimeMs: update["last_response_time_ms"].(int),
+			Error:          update["last_error"].(string),
+		}
+		results = append(results, result)
+		if result.Status == statusHealthy {
+			healthy++
+		}
+		// Report the metrics of the first healthy address, else the first one.
+		if base == nil || (result.Status == statusHealthy && base["status"] != statusHealthy) {
+			base = update
+		}
+	}
+	if base == nil {
+		return nil
+	}
+
+	switch {
+	case healthy == len(results):
+		base["status"] = statusHealthy
+	case healthy == 0:
+		base["status"] = statusUnhealthy
+	default:
+		base["status"] = statusDegraded
+		base["last_error"] = fmt.Sprintf("%d of %d addresses unhealthy", len(results)-healthy, len(results))
+	}
+	base["ip_results"] = results
+	base["last_bytes_sent"] = sent
+	base["last_bytes_received"] = received
+	return base
+}
+
+// pinnedDialer dials ip on the port of the requested address, bypassing DNS
+// so a specific backend can be probed under its hostname.
+func pinnedDialer(ip string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// autoMonitorName derives a monitor name from its URL: host, non-default
+// port, and path, without scheme, credentials, or query. Bare hosts, as used
+// by ping monitors, are returned as they are.
+func autoMonitorName(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	name := parsed.Host
+	if port := parsed.Port(); port != "" && port == defaultPort(parsed.Scheme) {
+		name = parsed.Hostname()
+	}
+	if path := strings.TrimRight(parsed.EscapedPath(), "/"); path != "" {
+		name += path
+	}
+	return name
+}
+
+// defaultPort returns the port implied by an http(s) scheme.
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// uniqueMonitorName returns base, or base with the lowest free " (n)"
+// suffix when a monitor in query already has that name.
+func uniqueMonitorName(query *gorm.DB, base string) (string, error) {
+	var taken []string
+	if err := query.Where("name = ? OR name LIKE ?", base, base+" (%)").Pluck("name", &taken).Error; err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, name := range taken {
+		used[name] = true
+	}
+	name := base
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s (%d)", base, n)
+	}
+	return name, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// defaultFailureThreshold is how many checks in a row must fail before a
+// notification when neither FAILURE_THRESHOLD nor the monitor's
+// failure_threshold is set.
+const defaultFailureThreshold = 1
+
+// maxFailureThreshold bounds failure_threshold and FAILURE_THRESHOLD.
+const maxFailureThreshold = 100
+
+// isFailing reports whether status calls for an alert.
+func isFailing(status string) bool {
+	return status == statusDegraded || status == statusUnhealthy
+}
+
+// failureThresholdFor returns how many failed checks in a row the monitor
+// needs before it is reported.
+func (mc *monitorChecker) failureThresholdFor(monitor *Monitor) int {
+	if monitor.FailureThreshold != nil {
+		return *monitor.FailureThreshold
+	}
+	return mc.failureThreshold
+}
+
+// alertTransition decides whether the result in update is reported, after
+// countFailures has run. A failure is reported once consecutive_failures
+// reaches the monitor's threshold, and then whenever the failing status
+// changes; a return to HEALTHY is reported only when the failure was. A
+// failure whose severity is log is held: it is not marked reported, so the
+// first check after its window ends reports it if the monitor still fails.
+// The status last reported is kept in alerted_status, and the status to
+// report as the previous one is returned with the failure's severity.
+func (mc *monitorChecker) alertTransition(monitor *Monitor, update map[string]interface{}) (string, string, bool) {
+	status, ok := update["status"].(string)
+	if !ok {
+		return "", "", false
+	}
+	switch {
+	case isFailing(status):
+		if status == monitor.AlertedStatus {
+			return "", "", false
+		}
+		failures, _ := update["consecutive_failures"].(int)
+		threshold := mc.failureThresholdFor(monitor)
+		if monitor.AlertedStatus == "" && failures < threshold {
+			return "", "", false
+		}
+		severity := mc.notificationSeverity(monitor, status, time.Now())
+		if severity == severityLog {
+			if status != monitor.Status || failures == threshold {
+				lastError, _ := update["last_error"].(string)
+				slog.Warn("notification held until its alerting hours", "monitor_id", monitor.ID, "monitor", monitor.Name, "status", status, "error", lastError)
+			}
+			return "", "", false
+		}
+		previous := monitor.AlertedStatus
+		if previous == "" {
+			previous = monitor.Status
+			if isFailing(previous) {
+				// The streak began before this check; it broke a healthy run.
+				previous = statusHealthy
+			}
+		}
+		update["alerted_status"] = status
+		return previous, severity, true
+	case status == statusHealthy && monitor.AlertedStatus != "":
+		previous := monitor.AlertedStatus
+		update["alerted_status"] = ""
+		return previous, "", true
+	}
+	return "", "", false
+}
+
+// notify tells the alerting integrations about a change of status that
+// alertTransition chose to report. previous is the status reported before
+// and severity that of a failure; recoveries have none. Deliveries run in
+// the background but are tracked so shutdown waits for them.
+func (mc *monitorChecker) notify(monitor *Monitor, previous, severity string, update map[string]interface{}) {
+	status, ok := update["status"].(string)
+	if !ok || status == previous {
+		return
+	}
+	if event, ok := mc.pagerDuty.event(monitor, previous, status, severity, update); ok {
+		mc.spawn(func() { mc.pagerDuty.send(event) })
+	}
+	if message, ok := mc.email.message(mc.db, monitor, previous, status, update); ok {
+		mc.spawn(func() { mc.email.send(monitor.ID, message) })
+	}
+	if message, ok := mc.slack.message(monitor, previous, status, update); ok {
+		mc.spawn(func() { mc.slack.send(monitor.ID, message) })
+	}
+}
+
+// postWithRetries POSTs a JSON body with any extra headers, making up to
+// attempts deliveries while the receiver answers with a rate limit or server
+// error or cannot be reached.
+func postWithRetries(client *http.Client, url string, body []byte, header http.Header, attempts int) error {
+	for attempt := 1; ; attempt++ {
+		err := postJSON(client, url, body, header)
+		if err == nil {
+			return nil
+		}
+		if _, retry := err.(retryableError); !retry || attempt == attempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+func postJSON(client *http.Client, url string, body []byte, header http.Header) error {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryableError{fmt.Errorf("status %d", resp.StatusCode)}
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// retryableError marks a delivery failure worth another attempt.
+type retryableError struct{ error }
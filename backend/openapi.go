@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// maxOpenAPISpecBytes bounds the size of an uploaded OpenAPI document.
+const maxOpenAPISpecBytes = 5 << 20
+
+// defaultOpenAPIExtension marks operations to import when no tag or
+// extension is given in the request.
+const defaultOpenAPIExtension = "x-monitor"
+
+// openAPIMethods lists path item keys that are operations, in the order they
+// are reported.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPIDocument holds the parts of an OpenAPI 3 or Swagger 2 document the
+// importer needs. YAML decoding also accepts JSON documents.
+type openAPIDocument struct {
+	Servers []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	Host     string                          `yaml:"host"`
+	BasePath string                          `yaml:"basePath"`
+	Schemes  []string                        `yaml:"schemes"`
+	Paths    map[string]map[string]yaml.Node `yaml:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string   `yaml:"operationId"`
+	Summary     string   `yaml:"summary"`
+	Tags        []string `yaml:"tags"`
+	Parameters  []struct {
+		In       string `yaml:"in"`
+		Required bool   `yaml:"required"`
+	} `yaml:"parameters"`
+	Extensions map[string]interface{} `yaml:",inline"`
+}
+
+// importedOperation reports one operation selected for import.
+type importedOperation struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	OperationID string   `json:"operation_id,omitempty"`
+	Monitor     *Monitor `json:"monitor,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+}
+
+// baseURL returns the address operations are relative to: the first server
+// with its variables set to their defaults, or Swagger 2's host and basePath.
+func (d openAPIDocument) baseURL() string {
+	if len(d.Servers) > 0 {
+		base := d.Servers[0].URL
+		for name, variable := range d.Servers[0].Variables {
+			base = strings.ReplaceAll(base, "{"+name+"}", variable.Default)
+		}
+		return base
+	}
+	if d.Host == "" {
+		return ""
+	}
+	scheme := "https"
+	if len(d.Schemes) > 0 {
+		scheme = d.Schemes[0]
+	}
+	return scheme + "://" + d.Host + d.BasePath
+}
+
+// selected reports whether the operation carries the extension with a true
+// value or is tagged with tag.
+func (op openAPIOperation) selected(extension, tag string) bool {
+	switch v := op.Extensions[extension].(type) {
+	case bool:
+		if v {
+			return true
+		}
+	case string:
+		if strings.EqualFold(v, "true") {
+			return true
+		}
+	}
+	if tag == "" {
+		return false
+	}
+	for _, t := range op.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedReason explains why a selected operation cannot become a
+// monitor, or returns "" when it can.
+func (op openAPIOperation) unsupportedReason(method, path string) string {
+	if method != "get" && method != "head" {
+		return "only GET and HEAD operations can be monitored"
+	}
+	if strings.Contains(path, "{") {
+		return "path parameters cannot be inferred"
+	}
+	for _, param := range op.Parameters {
+		if param.Required && param.In != "header" {
+			return fmt.Sprintf("requires a %s parameter", param.In)
+		}
+	}
+	return ""
+}
+
+// registerOpenAPIRoutes exposes POST /monitor/import/openapi, which creates
+// monitors for the health-relevant operations of an uploaded spec.
+func registerOpenAPIRoutes(router *gin.Engine, db *gorm.DB, checker *monitorChecker, keys apiKeys) {
+	router.POST("/monitor/import/openapi", authorize(keys, false), func(c *gin.Context) {
+		raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxOpenAPISpecBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+		if len(raw) > maxOpenAPISpecBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "OpenAPI document is too large"})
+			return
+		}
+		var doc openAPIDocument
+		if err := yaml.Unmarshal(raw, &doc); err != nil || len(doc.Paths) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid OpenAPI document"})
+			return
+		}
+
+		base := strings.TrimSpace(c.Query("base_url"))
+		if base == "" {
+			base = doc.baseURL()
+		}
+		base = strings.TrimRight(base, "/")
+		if parsed, err := url.Parse(base); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "An absolute server URL is required; set base_url"})
+			return
+		}
+		extension := strings.TrimSpace(c.DefaultQuery("extension", defaultOpenAPIExtension))
+		tag := strings.TrimSpace(c.Query("tag"))
+
+		paths := make([]string, 0, len(doc.Paths))
+		for path := range doc.Paths {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		imported, skipped := []importedOperation{}, []importedOperation{}
+		var monitors []Monitor
+		// GET and HEAD of one path share a URL; the first imported wins.
+		seen := map[string]bool{}
+		for _, path := range paths {
+			for _, method := range openAPIMethods {
+				node, ok := doc.Paths[path][method]
+				if !ok {
+					continue
+				}
+				var op openAPIOperation
+				if err := node.Decode(&op); err != nil || !op.selected(extension, tag) {
+					continue
+				}
+				entry := importedOperation{Method: strings.ToUpper(method), Path: path, OperationID: op.OperationID}
+				if entry.Reason = op.unsupportedReason(method, path); entry.Reason != "" {
+					skipped = append(skipped, entry)
+					continue
+				}
+				urlValue := base + path
+				if err := validateURL(urlValue); err != nil {
+					entry.Reason = "invalid URL"
+					skipped = append(skipped, entry)
+					continue
+				}
+				var existing int64
+				ownedMonitors(c, db).Model(&Monitor{}).Where("url = ?", urlValue).Count(&existing)
+				if existing > 0 || seen[urlValue] {
+					entry.Reason = "a monitor for this URL already exists"
+					skipped = append(skipped, entry)
+					continue
+				}
+				name := strings.TrimSpace(op.Summary)
+				if name == "" {
+					name = op.OperationID
+				}
+				if name == "" {
+					name = entry.Method + " " + path
+				}
+				monitors = append(monitors, Monitor{
+					Name:     name,
+					Type:     monitorTypeHTTP,
+					Method:   entry.Method,
+					URL:      urlValue,
+					Status:   statusUnknown,
+					OwnerKey: c.GetString(ownerContextKey),
+				})
+				seen[urlValue] = true
+				imported = append(imported, entry)
+			}
+		}
+
+		if len(monitors) > 0 {
+			if err := db.Create(&monitors).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create monitors"})
+				return
+			}
+		}
+		for i := range monitors {
+			imported[i].Monitor = &monitors[i]
+			checker.triggerCheck(monitors[i].ID)
+		}
+
+		code := http.StatusOK
+		if len(imported) > 0 {
+			code = http.StatusCreated
+		}
+		c.JSON(code, gin.H{"imported": imported, "skipped": skipped})
+	})
+}
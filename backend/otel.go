@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultOTelServiceName names the resource when OTEL_SERVICE_NAME is unset.
+const defaultOTelServiceName = "uselessmonitor"
+
+// otelBufferSize bounds the spans waiting to be exported.
+const otelBufferSize = 1000
+
+// OTLP span kind and status codes, as numbers in the JSON encoding.
+const (
+	otlpSpanKindClient = 3
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+)
+
+// otlpScopeName identifies this service as the instrumentation scope.
+const otlpScopeName = "uselessmonitor-backend"
+
+// otlpTracesPath is appended to OTEL_EXPORTER_OTLP_ENDPOINT.
+const otlpTracesPath = "/v1/traces"
+
+// otlpExportAttempts bounds deliveries of one batch of spans.
+const otlpExportAttempts = 3
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) otlpAttribute {
+	encoded := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &encoded}}
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// checkSpan collects the sub-timings of one check while it runs. It travels
+// in the check's context so the HTTP probe can add to it.
+type checkSpan struct {
+	start  time.Time
+	mu     sync.Mutex
+	events []otlpEvent
+}
+
+type checkSpanKey struct{}
+
+func spanFromContext(ctx context.Context) *checkSpan {
+	span, _ := ctx.Value(checkSpanKey{}).(*checkSpan)
+	return span
+}
+
+// addTrace records the phases an HTTP attempt went through as span events.
+func (s *checkSpan) addTrace(trace *checkTrace, ip string) {
+	if s == nil || trace == nil {
+		return
+	}
+	var attributes []otlpAttribute
+	if ip != "" {
+		attributes = []otlpAttribute{stringAttribute("network.peer.address", ip)}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, phase := range trace.phases() {
+		s.events = append(s.events, otlpEvent{TimeUnixNano: unixNano(phase.at), Name: phase.name, Attributes: attributes})
+	}
+}
+
+// spanExporter sends every check as an OTLP span to a collector, encoded as
+// JSON over HTTP. Like the Kafka publisher it never blocks the checker:
+// spans go through a bounded buffer and are dropped (and counted) when it is
+// full. A nil exporter does nothing.
+type spanExporter struct {
+	client  *http.Client
+	url     string
+	header  http.Header
+	service string
+	spans   chan otlpSpan
+	dropped atomic.Int64
+}
+
+// newSpanExporter returns an exporter posting to tracesURL, or to endpoint
+// with /v1/traces appended, or nil when neither is set. headers is a list of
+// name=value pairs separated by commas, as in OTEL_EXPORTER_OTLP_HEADERS.
+func newSpanExporter(endpoint, tracesURL, headers, service string) *spanExporter {
+	if tracesURL == "" && endpoint != "" {
+		tracesURL = strings.TrimRight(endpoint, "/") + otlpTracesPath
+	}
+	if tracesURL == "" {
+		return nil
+	}
+	if service == "" {
+		service = defaultOTelServiceName
+	}
+	header := http.Header{}
+	for _, pair := range splitList(headers) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = decoded
+		}
+		header.Set(strings.TrimSpace(name), value)
+	}
+	return &spanExporter{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		url:     tracesURL,
+		header:  header,
+		service: service,
+		spans:   make(chan otlpSpan, otelBufferSize),
+	}
+}
+
+// start drains the buffer to the collector until ctx is cancelled.
+func (e *spanExporter) start(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case span := <-e.spans:
+				batch := []otlpSpan{span}
+			drain:
+				for len(batch) < cap(e.spans) {
+					select {
+					case next := <-e.spans:
+						batch = append(batch, next)
+					default:
+						break drain
+					}
+				}
+				e.send(batch)
+			}
+		}
+	}()
+}
+
+func (e *spanExporter) send(batch []otlpSpan) {
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{stringAttribute("service.name", e.service)},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": otlpScopeName},
+				"spans": batch,
+			}},
+		}},
+	})
+	if err != nil {
+		slog.Error("otlp encoding failed", "spans", len(batch), "error", err)
+		return
+	}
+	if err := postWithRetries(e.client, e.url, body, e.header, otlpExportAttempts); err != nil {
+		slog.Error("otlp export failed", "spans", len(batch), "error", err)
+	}
+}
+
+// begin starts the span of a check, returning the context the probes should
+// use and the span to pass to finish.
+func (e *spanExporter) begin(ctx context.Context) (context.Context, *checkSpan) {
+	if e == nil {
+		return ctx, nil
+	}
+	span := &checkSpan{start: time.Now()}
+	return context.WithValue(ctx, checkSpanKey{}, span), span
+}
+
+// finish queues the span of a completed check with its outcome.
+func (e *spanExporter) finish(span *checkSpan, monitor *Monitor, update map[string]interface{}) {
+	if e == nil || span == nil {
+		return
+	}
+	status, _ := update["status"].(string)
+	code, _ := update["last_response_code"].(int)
+	latency, _ := update["last_response_time_ms"].(int)
+	attempts, _ := update["last_attempts"].(int)
+	lastError, _ := update["last_error"].(string)
+	category, _ := update["last_error_category"].(string)
+
+	attributes := []otlpAttribute{
+		intAttribute("monitor.id", int64(monitor.ID)),
+		stringAttribute("monitor.name", monitor.Name),
+		stringAttribute("monitor.type", monitor.Type),
+		stringAttribute("url.full", monitor.redactedURL()),
+		intAttribute("check.response_time_ms", int64(latency)),
+	}
+	if status != "" {
+		attributes = append(attributes, stringAttribute("check.status", status))
+	}
+	if monitor.probesHTTP() {
+		attributes = append(attributes, stringAttribute("http.request.method", monitor.requestMethod()))
+	}
+	if code != 0 {
+		attributes = append(attributes, intAttribute("http.response.status_code", int64(code)))
+	}
+	if attempts > 0 {
+		attributes = append(attributes, intAttribute("check.attempts", int64(attempts)))
+	}
+	if category != "" {
+		attributes = append(attributes, stringAttribute("error.type", category))
+	}
+
+	out := otlpSpan{
+		TraceID:           randomHex(16),
+		SpanID:            randomHex(8),
+		Name:              "monitor.check",
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: unixNano(span.start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes:        attributes,
+	}
+	switch status {
+	case statusHealthy:
+		out.Status = otlpStatus{Code: otlpStatusOK}
+	case statusUnhealthy:
+		out.Status = otlpStatus{Code: otlpStatusError, Message: lastError}
+	}
+	span.mu.Lock()
+	out.Events = span.events
+	span.mu.Unlock()
+
+	select {
+	case e.spans <- out:
+	default:
+		dropped := e.dropped.Add(1)
+		slog.Warn("otlp buffer full, dropped span", "monitor_id", monitor.ID, "dropped", dropped)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// maxPagerDutySummary is the Events API limit on payload.summary.
+const maxPagerDutySummary = 1024
+
+// pagerDutyAttempts bounds deliveries of one event when PagerDuty answers
+// with a rate limit or server error.
+const pagerDutyAttempts = 3
+
+// pagerDutyEvent is an Events API v2 request body.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Component     string                 `json:"component,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// pagerDutyNotifier opens a PagerDuty incident when a monitor starts failing
+// and resolves it when the monitor recovers. Events share a dedup key per
+// monitor, so a change from DEGRADED to UNHEALTHY updates the same incident.
+type pagerDutyNotifier struct {
+	client *http.Client
+	url    string
+	// routingKey is used for monitors without their own key.
+	routingKey string
+}
+
+func newPagerDutyNotifier(url, routingKey string) *pagerDutyNotifier {
+	if url == "" {
+		url = defaultPagerDutyEventsURL
+	}
+	return &pagerDutyNotifier{client: &http.Client{Timeout: 10 * time.Second}, url: url, routingKey: routingKey}
+}
+
+// event returns the event for a monitor moving from previous to status, or
+// false when the change needs none or no routing key applies. severity is
+// used for incidents that are triggered.
+func (n *pagerDutyNotifier) event(monitor *Monitor, previous, status, severity string, update map[string]interface{}) (pagerDutyEvent, bool) {
+	if n == nil {
+		return pagerDutyEvent{}, false
+	}
+	routingKey := monitor.PagerDutyRoutingKey
+	if routingKey == "" {
+		routingKey = n.routingKey
+	}
+	if routingKey == "" {
+		return pagerDutyEvent{}, false
+	}
+	event := pagerDutyEvent{RoutingKey: routingKey, DedupKey: fmt.Sprintf("uselessmonitor-%d", monitor.ID)}
+	switch {
+	case isFailing(status):
+		lastError, _ := update["last_error"].(string)
+		summary := fmt.Sprintf("%s is %s", monitor.Name, status)
+		if lastError != "" {
+			summary += ": " + lastError
+		}
+		if len(summary) > maxPagerDutySummary {
+			summary = summary[:maxPagerDutySummary]
+		}
+		details := map[string]interface{}{
+			"url":              monitor.redactedURL(),
+			"type":             monitor.Type,
+			"previous_status":  previous,
+			"response_code":    update["last_response_code"],
+			"response_time_ms": update["last_response_time_ms"],
+			"error":            lastError,
+			"error_category":   update["last_error_category"],
+		}
+		if monitor.FailureMessage != "" {
+			details["failure_message"] = monitor.FailureMessage
+		}
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       summary,
+			Source:        monitor.redactedURL(),
+			Severity:      severity,
+			Component:     monitor.Name,
+			CustomDetails: details,
+		}
+	case status == statusHealthy && isFailing(previous):
+		event.EventAction = "resolve"
+	default:
+		return pagerDutyEvent{}, false
+	}
+	return event, true
+}
+
+// send delivers event, retrying rate limits and server errors.
+func (n *pagerDutyNotifier) send(event pagerDutyEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("pagerduty event not sent", "action", event.EventAction, "dedup_key", event.DedupKey, "error", err)
+		return
+	}
+	if err := postWithRetries(n.client, n.url, body, nil, pagerDutyAttempts); err != nil {
+		slog.Error("pagerduty event failed", "action", event.EventAction, "dedup_key", event.DedupKey, "error", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// pageRequest is a validated ?page=&page_size= pair; page is 1-based.
+type pageRequest struct {
+	page int
+	size int
+}
+
+// parsePage reads the pagination query parameters, applying the defaults
+// when they are absent.
+func parsePage(c *gin.Context) (pageRequest, error) {
+	req := pageRequest{page: 1, size: defaultPageSize}
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return req, fmt.Errorf("page must be a positive integer")
+		}
+		req.page = page
+	}
+	if raw := c.Query("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > maxPageSize {
+			return req, fmt.Errorf("page_size must be between 1 and %d", maxPageSize)
+		}
+		req.size = size
+	}
+	return req, nil
+}
+
+// apply limits query to the requested page.
+func (p pageRequest) apply(query *gorm.DB) *gorm.DB {
+	return query.Limit(p.size).Offset((p.page - 1) * p.size)
+}
+
+// setHeaders reports the page and the total number of items in response
+// headers, leaving the body a plain array.
+func (p pageRequest) setHeaders(c *gin.Context, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.Header("X-Page", strconv.Itoa(p.page))
+	c.Header("X-Page-Size", strconv.Itoa(p.size))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+)
+
+// Values of path_sampling.
+const (
+	pathSamplingRandom     = "random"
+	pathSamplingRoundRobin = "round_robin"
+)
+
+// maxSamplePaths bounds sample_paths.
+const maxSamplePaths = 100
+
+// probesHTTP reports whether checks of the monitor are HTTP requests.
+func (m *Monitor) probesHTTP() bool {
+	return !m.isType(monitorTypeDB) && !m.isType(monitorTypeUDP) && !m.isType(monitorTypeTCP) && !m.isType(monitorTypePing) && !m.isType(monitorTypeTransaction)
+}
+
+// validateSamplePaths requires every sample path to be an absolute path on
+// the monitor's own host, and the mode to be known.
+func validateSamplePaths(paths stringList, mode string) error {
+	if len(paths) > maxSamplePaths {
+		return fmt.Errorf("sample_paths cannot list more than %d paths", maxSamplePaths)
+	}
+	for _, path := range paths {
+		parsed, err := url.Parse(path)
+		if err != nil || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || parsed.Host != "" {
+			return fmt.Errorf("sample path %q must start with a single /", path)
+		}
+	}
+	switch mode {
+	case "", pathSamplingRandom, pathSamplingRoundRobin:
+		return nil
+	}
+	return fmt.Errorf("path_sampling must be random or round_robin")
+}
+
+// samplePath picks the path for this check of a monitor with sample_paths and
+// returns a copy of the monitor pointed at it. Monitors without paths, or
+// whose checks are not HTTP, are returned as they are with an empty path.
+// The copy is only used for the probe and is never saved.
+func (m *Monitor) samplePath() (*Monitor, string) {
+	if len(m.SamplePaths) == 0 || !m.probesHTTP() {
+		return m, ""
+	}
+	var path string
+	if m.PathSampling == pathSamplingRoundRobin {
+		path = m.SamplePaths[m.PathRotation%len(m.SamplePaths)]
+	} else {
+		path = m.SamplePaths[rand.Intn(len(m.SamplePaths))]
+	}
+	sampled := *m
+	sampled.URL = urlOrigin(m.URL) + path
+	return &sampled, path
+}
+
+// urlOrigin returns raw up to the end of its authority, so ${NAME}
+// placeholders in credentials survive untouched.
+func urlOrigin(raw string) string {
+	scheme := strings.Index(raw, "://")
+	if scheme < 0 {
+		return raw
+	}
+	if end := strings.IndexAny(raw[scheme+3:], "/?#"); end >= 0 {
+		return raw[:scheme+3+end]
+	}
+	return raw
+}
+
+// recordSampledPath notes which path a check probed and replaces its status
+// with the rollup of the latest status of every sample path, so one failing
+// path keeps the monitor DEGRADED until it is sampled healthy again.
+func recordSampledPath(monitor *Monitor, path string, update map[string]interface{}) {
+	if path == "" {
+		return
+	}
+	update["last_path"] = path
+	update["path_rotation"] = monitor.PathRotation + 1
+	status, ok := update["status"].(string)
+	if !ok {
+		return
+	}
+
+	statuses := stringMap{}
+	for _, p := range monitor.SamplePaths {
+		if previous, ok := monitor.PathStatuses[p]; ok {
+			statuses[p] = previous
+		}
+	}
+	statuses[path] = status
+	update["path_statuses"] = statuses
+
+	all := make([]string, 0, len(statuses))
+	failing := 0
+	for _, s := range statuses {
+		all = append(all, s)
+		if s != statusHealthy {
+			failing++
+		}
+	}
+	update["status"] = rollupStatus(all)
+	if lastError, _ := update["last_error"].(string); failing > 0 && lastError == "" {
+		update["last_error"] = fmt.Sprintf("%d of %d sampled paths not healthy", failing, len(statuses))
+	}
+}
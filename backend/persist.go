@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultPersistRetryCount is how many times a failed write of a check's
+// result is retried when PERSIST_RETRY_COUNT is unset.
+const defaultPersistRetryCount = 3
+
+// maxPersistRetryCount bounds PERSIST_RETRY_COUNT.
+const maxPersistRetryCount = 10
+
+// persistBackoff is the pause before the first retry of a write; later
+// retries wait proportionally longer.
+const persistBackoff = 100 * time.Millisecond
+
+// maxPendingPersists bounds the results held in memory after their writes
+// failed. The oldest is dropped when it is full.
+const maxPendingPersists = 1000
+
+// pendingPersist is a check result whose write failed.
+type pendingPersist struct {
+	monitorID uint
+	update    map[string]interface{}
+	result    *CheckResult
+}
+
+// resultStore saves check results, retrying failed writes with a backoff and
+// keeping results that still fail in memory to try again on the next tick.
+type resultStore struct {
+	db      *gorm.DB
+	retries int
+
+	mu      sync.Mutex
+	pending []pendingPersist
+	// failed counts results whose writes failed every retry; dropped counts
+	// those lost because the buffer was full.
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+func newResultStore(db *gorm.DB, retries int) *resultStore {
+	return &resultStore{db: db, retries: retries}
+}
+
+// write saves the monitor's column updates and its history row, if any, in
+// one transaction. A late write leaves the columns alone when a newer check
+// of the monitor has been saved meanwhile, so it cannot roll its status back.
+func (s *resultStore) write(entry pendingPersist, late bool) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Monitor{}).Where("id = ?", entry.monitorID)
+		if checkedAt, ok := entry.update["last_check"].(time.Time); late && ok {
+			query = query.Where("last_check IS NULL OR last_check <= ?", checkedAt)
+		}
+		// Check results are not edits, so they leave updated_at alone.
+		if err := query.UpdateColumns(entry.update).Error; err != nil {
+			return err
+		}
+		if entry.result != nil {
+			if err := tx.Create(entry.result).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// save persists the result of a check of monitorID, retrying a failed write
+// up to s.retries times. A result that still cannot be written is kept for
+// flush.
+func (s *resultStore) save(monitorID uint, update map[string]interface{}) {
+	entry := pendingPersist{monitorID: monitorID, update: update}
+	if result, ok := checkResultFromUpdate(monitorID, update); ok {
+		entry.result = &result
+	}
+	var err error
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * persistBackoff)
+		}
+		if err = s.write(entry, false); err == nil {
+			return
+		}
+	}
+	failed := s.failed.Add(1)
+	slog.Error("result not saved, retrying on the next tick", "monitor_id", monitorID, "attempts", s.retries+1, "failed", failed, "error", err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= maxPendingPersists {
+		s.pending = s.pending[1:]
+		dropped := s.dropped.Add(1)
+		slog.Error("pending result buffer full, dropped the oldest result", "dropped", dropped)
+	}
+	s.pending = append(s.pending, entry)
+}
+
+// flush tries once more to write every pending result, in the order the
+// checks finished, and keeps those that fail again.
+func (s *resultStore) flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	var kept []pendingPersist
+	for i, entry := range pending {
+		if err := s.write(entry, true); err != nil {
+			slog.Error("pending results still not saved", "pending", len(pending)-i, "error", err)
+			kept = pending[i:]
+			break
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(kept, s.pending...)
+	if overflow := len(s.pending) - maxPendingPersists; overflow > 0 {
+		s.pending = s.pending[overflow:]
+		s.dropped.Add(int64(overflow))
+	}
+}
+
+// state reports how many results failed to save and how many wait in memory.
+func (s *resultStore) state() (failed, pending, dropped int64) {
+	s.mu.Lock()
+	pending = int64(len(s.pending))
+	s.mu.Unlock()
+	return s.failed.Load(), pending, s.dropped.Load()
+}
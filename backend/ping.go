@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const monitorTypePing = "ping"
+
+// ICMP socket modes. Raw sockets need root or CAP_NET_RAW; unprivileged
+// "ping sockets" need the group to be within net.ipv4.ping_group_range.
+const (
+	pingModePrivileged   = "privileged"
+	pingModeUnprivileged = "unprivileged"
+	pingModeUnavailable  = "unavailable"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+var pingPayload = []byte("uselessmonitor-ping")
+
+var pingSequence atomic.Uint32
+
+// detectPingMode reports which kind of ICMP socket this process may open,
+// preferring raw sockets.
+func detectPingMode() string {
+	if conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		conn.Close()
+		return pingModePrivileged
+	}
+	if conn, err := icmp.ListenPacket("udp4", "0.0.0.0"); err == nil {
+		conn.Close()
+		return pingModeUnprivileged
+	}
+	return pingModeUnavailable
+}
+
+// checkPing sends one ICMP echo request to the host in the monitor's URL and
+// waits for the reply within the request timeout.
+func (mc *monitorChecker) checkPing(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	update := map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            "",
+		"last_error_category":   "",
+	}
+
+	rtt, err := ping(ctx, mc.pingMode, strings.TrimSpace(monitor.URL))
+	if err != nil {
+		slog.Error("ping failed", "monitor_id", monitor.ID, "error", err)
+		update["last_error"] = err.Error()
+		update["last_error_category"] = classifyRequestError(err)
+		return update
+	}
+
+	update["last_response_time_ms"] = int(rtt / time.Millisecond)
+	update["status"] = statusHealthy
+	return update
+}
+
+func ping(ctx context.Context, mode, host string) (time.Duration, error) {
+	if mode == pingModeUnavailable || mode == "" {
+		return 0, errors.New("ICMP sockets are not permitted for this process")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	if len(addrs) == 0 {
+		return 0, fmt.Errorf("no addresses for %s", host)
+	}
+	target := addrs[0].IP
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			target = addr.IP
+			break
+		}
+	}
+
+	network, listen, protocol := "ip4:icmp", "0.0.0.0", protocolICMP
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if target.To4() == nil {
+		network, listen, protocol = "ip6:ipv6-icmp", "::", protocolIPv6ICMP
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	privileged := mode == pingModePrivileged
+	if !privileged {
+		network = "udp4"
+		if target.To4() == nil {
+			network = "udp6"
+		}
+	}
+
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	id := os.Getpid() & 0xffff
+	seq := int(pingSequence.Add(1) & 0xffff)
+	request, err := (&icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: pingPayload},
+	}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var dst net.Addr = &net.IPAddr{IP: target}
+	if !privileged {
+		dst = &net.UDPAddr{IP: target}
+	}
+	start := time.Now()
+	if _, err := conn.WriteTo(request, dst); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || !bytes.Equal(echo.Data, pingPayload) || !samePeer(peer, target) {
+			continue
+		}
+		// Ping sockets rewrite the identifier, so only raw replies can be
+		// matched on it.
+		if privileged && echo.ID != id {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
+
+func samePeer(peer net.Addr, target net.IP) bool {
+	switch addr := peer.(type) {
+	case *net.IPAddr:
+		return addr.IP.Equal(target)
+	case *net.UDPAddr:
+		return addr.IP.Equal(target)
+	}
+	return false
+}
+
+// validPingHost accepts a bare hostname or IP address.
+func validPingHost(raw string) error {
+	host := strings.TrimSpace(raw)
+	if net.ParseIP(host) != nil || validHostname(host) {
+		return nil
+	}
+	return errors.New("ping monitors need a hostname or IP address")
+}
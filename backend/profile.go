@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// Client profiles shape the transport and default headers of HTTP checks.
+const (
+	clientProfileDefault = "default"
+	clientProfileBrowser = "browser"
+	clientProfileMinimal = "minimal"
+)
+
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// browserCipherSuites follows the TLS 1.2 suite preference of current
+// Chromium releases. TLS 1.3 suites are not configurable in Go.
+var browserCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+}
+
+// normalizeClientProfile lower-cases a profile name and maps "" to the
+// default profile. ok is false for unknown profiles.
+func normalizeClientProfile(raw string) (string, bool) {
+	profile := strings.ToLower(strings.TrimSpace(raw))
+	switch profile {
+	case "":
+		return clientProfileDefault, true
+	case clientProfileDefault, clientProfileBrowser, clientProfileMinimal:
+		return profile, true
+	}
+	return "", false
+}
+
+// usesCustomProfile reports whether the monitor needs its own transport.
+func (m *Monitor) usesCustomProfile() bool {
+	return m.ClientProfile != "" && m.ClientProfile != clientProfileDefault
+}
+
+// applyClientProfile adjusts a cloned transport for the profile. Any TLS
+// config already set (e.g. an SNI override) is kept and extended.
+func applyClientProfile(profile string, transport *http.Transport) {
+	switch profile {
+	case clientProfileBrowser:
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+		transport.TLSClientConfig.CipherSuites = browserCipherSuites
+		transport.TLSClientConfig.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384}
+		transport.ForceAttemptHTTP2 = true
+	case clientProfileMinimal:
+		// HTTP/1.1 only, one connection per check, no transparent gzip.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		transport.DisableKeepAlives = true
+		transport.DisableCompression = true
+	}
+}
+
+// applyProfileHeaders sets the request headers a profile sends by default.
+func applyProfileHeaders(profile string, req *http.Request) {
+	if profile != clientProfileBrowser {
+		return
+	}
+	req.Header.Set("User-Agent", browserUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	req.Header.Set("Sec-Fetch-Site", "none")
+}
@@ -0,0 +1,38 @@
+package main
+
+import "time"
+
+// confirmRecovery holds a failing monitor in its current status until it has
+// reported HEALTHY continuously for recovery_confirmation_seconds, so a brief
+// recovery followed by another failure never registers as a recovery. While
+// waiting, recovering_since records when the healthy streak began.
+func confirmRecovery(monitor *Monitor, update map[string]interface{}) {
+	status, ok := update["status"].(string)
+	if !ok {
+		return
+	}
+	if status != statusHealthy {
+		if monitor.RecoveringSince != nil {
+			update["recovering_since"] = nil
+		}
+		return
+	}
+	if monitor.RecoveryConfirmationSeconds <= 0 || (monitor.Status != statusDegraded && monitor.Status != statusUnhealthy) {
+		if monitor.RecoveringSince != nil {
+			update["recovering_since"] = nil
+		}
+		return
+	}
+
+	now := time.Now()
+	if monitor.RecoveringSince == nil {
+		update["recovering_since"] = now
+		update["status"] = monitor.Status
+		return
+	}
+	if now.Sub(*monitor.RecoveringSince) < time.Duration(monitor.RecoveryConfirmationSeconds)*time.Second {
+		update["status"] = monitor.Status
+		return
+	}
+	update["recovering_since"] = nil
+}
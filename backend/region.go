@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// stringList is a list of strings persisted as a JSON text column.
+type stringList []string
+
+func (l stringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(l)
+	return string(encoded), err
+}
+
+func (l *stringList) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported stringList value %T", value)
+	}
+	if len(raw) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(raw, l)
+}
+
+func (stringList) GormDataType() string {
+	return "text"
+}
+
+// checkRegion reads the monitor's region header (for example a CDN PoP
+// identifier) and reports the observed value and, when it is not one of
+// the expected regions, a failure message. Entries ending in "*" match by
+// prefix so "FRA*" accepts every Frankfurt PoP; matching ignores case.
+func checkRegion(monitor *Monitor, headers http.Header) (string, string) {
+	observed := strings.TrimSpace(headers.Get(monitor.RegionHeader))
+	if len(monitor.ExpectedRegions) == 0 {
+		return observed, ""
+	}
+	if observed == "" {
+		return "", fmt.Sprintf("region header %s missing", monitor.RegionHeader)
+	}
+	for _, expected := range monitor.ExpectedRegions {
+		if prefix, ok := strings.CutSuffix(expected, "*"); ok {
+			if strings.HasPrefix(strings.ToLower(observed), strings.ToLower(prefix)) {
+				return observed, ""
+			}
+		} else if strings.EqualFold(observed, expected) {
+			return observed, ""
+		}
+	}
+	return observed, fmt.Sprintf("served from unexpected region %q (expected %s)", observed, strings.Join(monitor.ExpectedRegions, ", "))
+}
+
+// normalizeList trims each entry and drops empty ones.
+func normalizeList(values []string) stringList {
+	var normalized stringList
+	for _, value := range values {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			normalized = append(normalized, trimmed)
+		}
+	}
+	return normalized
+}
+
+// validateRegionAssertion requires a valid header name whenever regions are
+// expected.
+func validateRegionAssertion(header string, regions stringList) error {
+	if header == "" {
+		if len(regions) > 0 {
+			return fmt.Errorf("expected_regions requires region_header")
+		}
+		return nil
+	}
+	if !validHeaderName(header) {
+		return fmt.Errorf("invalid region_header")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// replayResponse is one recorded answer in a replay fixture file. Error
+// replaces the response with a transport failure: "timeout", "refused",
+// "dns", or any other text for a generic connection error.
+type replayResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	DelayMs int               `json:"delay_ms"`
+	Error   string            `json:"error"`
+}
+
+// replayStore answers HTTP checks from fixtures instead of the network. Each
+// URL maps to a sequence of responses served in order; the last one repeats
+// once the sequence is exhausted. A nil store disables replay.
+type replayStore struct {
+	path     string
+	fixtures map[string][]replayResponse
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// loadReplayStore reads the JSON fixture file at path, or returns nil when
+// path is empty.
+func loadReplayStore(path string) (*replayStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures map[string][]replayResponse
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for url, responses := range fixtures {
+		if len(responses) == 0 {
+			return nil, fmt.Errorf("fixture %s has no responses", url)
+		}
+	}
+	return &replayStore{path: path, fixtures: fixtures, served: map[string]int{}}, nil
+}
+
+// next returns the response to serve for url.
+func (rs *replayStore) next(url string) (replayResponse, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	responses, ok := rs.fixtures[url]
+	if !ok {
+		return replayResponse{}, false
+	}
+	i := rs.served[url]
+	if i < len(responses)-1 {
+		rs.served[url] = i + 1
+	}
+	return responses[i], true
+}
+
+// RoundTrip implements http.RoundTripper so replayed responses pass through
+// the same client, decoding, and assertion code as real ones.
+func (rs *replayStore) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, ok := rs.next(req.URL.String())
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s", req.URL)
+	}
+	if recorded.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(recorded.DelayMs) * time.Millisecond):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	switch recorded.Error {
+	case "":
+	case "timeout":
+		return nil, fmt.Errorf("replayed timeout: %w", context.DeadlineExceeded)
+	case "refused":
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	case "dns":
+		return nil, &net.DNSError{Err: "no such host", Name: req.URL.Hostname(), IsNotFound: true}
+	default:
+		return nil, errors.New(recorded.Error)
+	}
+
+	status := recorded.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header, len(recorded.Headers))
+	for name, value := range recorded.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// replayUnsupported is the result of a non-HTTP monitor in replay mode, which
+// must not reach the network either.
+func replayUnsupported(monitor *Monitor) map[string]interface{} {
+	return map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            fmt.Sprintf("%s monitors cannot be replayed", strings.ToLower(strings.TrimSpace(monitor.Type))),
+		"last_error_category":   errorCategoryOther,
+	}
+}
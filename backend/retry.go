@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// defaultRetryCount is how many times an UNHEALTHY probe is repeated when
+// neither CHECK_RETRY_COUNT nor the monitor's retry_count is set.
+const defaultRetryCount = 2
+
+// maxRetryCount bounds retry_count and CHECK_RETRY_COUNT.
+const maxRetryCount = 5
+
+// retryBackoff is the pause before the first retry; each later retry waits
+// one step longer.
+const retryBackoff = 500 * time.Millisecond
+
+// retriesFor returns how many retries the monitor gets.
+func (mc *monitorChecker) retriesFor(monitor *Monitor) int {
+	if monitor.RetryCount != nil {
+		return *monitor.RetryCount
+	}
+	return mc.retries
+}
+
+// checkBudget is the longest a check of the monitor can take when every
+// attempt runs to its timeout: the first attempt, which may be cold, each
+// retry, and the pauses between them.
+func (mc *monitorChecker) checkBudget(monitor *Monitor) time.Duration {
+	retries := mc.retriesFor(monitor)
+	first := monitor.checkTimeout()
+	if cold := time.Duration(monitor.ColdTimeoutSeconds) * time.Second; cold > first {
+		first = cold
+	}
+	backoff := time.Duration(retries*(retries+1)/2) * retryBackoff
+	return first + time.Duration(retries)*monitor.checkTimeout() + backoff
+}
+
+// validateCheckBudget rejects a monitor whose own timeout_seconds,
+// cold_timeout_seconds, or retry_count would let its attempts run past
+// MAX_CHECK_DURATION, which would cancel the later retries. A fallback_url
+// repeats every attempt against the fallback, doubling the budget. Monitors
+// that set none of the three follow the service-wide defaults and are not
+// checked.
+func (mc *monitorChecker) validateCheckBudget(monitor *Monitor) error {
+	if monitor.TimeoutSeconds == 0 && monitor.ColdTimeoutSeconds == 0 && monitor.RetryCount == nil {
+		return nil
+	}
+	budget := mc.checkBudget(monitor)
+	including := ""
+	if monitor.FallbackURL != "" {
+		budget *= 2
+		including = " including the fallback"
+	}
+	if budget <= mc.maxDuration {
+		return nil
+	}
+	return fmt.Errorf("a timeout of %s with %d retries can take %s%s, longer than MAX_CHECK_DURATION (%s); lower timeout_seconds, cold_timeout_seconds, or retry_count",
+		monitor.checkTimeout(), mc.retriesFor(monitor), budget, including, mc.maxDuration)
+}
+
+// probeWithRetries probes the monitor, repeating an UNHEALTHY result with a
+// growing pause until it passes or the retries run out, and returns the
+// last attempt's update with last_attempts set and the traffic of every
+// attempt counted. Cancellation is not retried.
+func (mc *monitorChecker) probeWithRetries(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	retries := mc.retriesFor(monitor)
+	timeout, cold := monitor.firstAttemptTimeout()
+	if cold {
+		slog.Info("monitor is cold; extending the first attempt", "monitor_id", monitor.ID, "timeout", timeout.String())
+	}
+	sent, received := 0, 0
+	for attempt := 1; ; attempt++ {
+		update := mc.probe(ctx, monitor, timeout)
+		timeout = monitor.checkTimeout()
+		if update == nil {
+			return nil
+		}
+		update["last_attempts"] = attempt
+		sent += intValue(update["last_bytes_sent"])
+		received += intValue(update["last_bytes_received"])
+		if sent > 0 || received > 0 {
+			update["last_bytes_sent"] = sent
+			update["last_bytes_received"] = received
+		}
+		if update["status"] != statusUnhealthy || update["last_error_category"] == errorCategoryCanceled || attempt > retries {
+			return update
+		}
+		slog.Warn("attempt failed; retrying", "monitor_id", monitor.ID, "attempt", attempt, "error", update["last_error"])
+		select {
+		case <-time.After(time.Duration(attempt) * retryBackoff):
+		case <-ctx.Done():
+			return update
+		}
+	}
+}
+
+// probe runs a single attempt of the check for the monitor's type, bounded
+// by timeout.
+func (mc *monitorChecker) probe(ctx context.Context, monitor *Monitor, timeout time.Duration) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case mc.replay != nil && !monitor.probesHTTP():
+		return replayUnsupported(monitor)
+	case mc.replay != nil:
+		// Fixtures are keyed by URL, so skip per-address resolution.
+		return mc.checkHTTP(ctx, monitor, "")
+	case monitor.isType(monitorTypeDB):
+		return mc.checkDatabase(ctx, monitor)
+	case monitor.isType(monitorTypeUDP):
+		return mc.checkUDP(ctx, monitor)
+	case monitor.isType(monitorTypeTCP):
+		return mc.checkTCP(ctx, monitor)
+	case monitor.isType(monitorTypePing):
+		return mc.checkPing(ctx, monitor)
+	case monitor.isType(monitorTypeTransaction):
+		return mc.checkTransaction(ctx, monitor)
+	case monitor.CheckAllIPs:
+		return mc.checkAllIPs(ctx, monitor)
+	default:
+		return mc.checkHTTP(ctx, monitor, "")
+	}
+}
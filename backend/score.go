@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+const maxMonitorPriority = 10
+
+// healthScoreWeights controls how much each factor contributes to the fleet
+// health score. Weights are relative; they do not need to sum to one.
+type healthScoreWeights struct {
+	Availability float64 `json:"availability"`
+	Latency      float64 `json:"latency"`
+}
+
+func healthScoreWeightsFromEnv() healthScoreWeights {
+	weights := healthScoreWeights{
+		Availability: getEnvAsFloat("HEALTH_SCORE_AVAILABILITY_WEIGHT", 0.8),
+		Latency:      getEnvAsFloat("HEALTH_SCORE_LATENCY_WEIGHT", 0.2),
+	}
+	if weights.Availability < 0 {
+		weights.Availability = 0
+	}
+	if weights.Latency < 0 {
+		weights.Latency = 0
+	}
+	if weights.Availability+weights.Latency == 0 {
+		weights.Availability = 1
+	}
+	return weights
+}
+
+// healthScore is the computed fleet score together with the inputs that
+// produced it.
+type healthScore struct {
+	Score               float64            `json:"score"`
+	Monitors            int                `json:"monitors"`
+	ScoredMonitors      int                `json:"scored_monitors"`
+	AvailabilityScore   float64            `json:"availability_score"`
+	LatencyScore        float64            `json:"latency_score"`
+	LatencySamples      int                `json:"latency_samples"`
+	TotalPriorityWeight float64            `json:"total_priority_weight"`
+	Weights             healthScoreWeights `json:"weights"`
+}
+
+// monitorPriorityWeight treats an unset priority as 1 so monitors created
+// before priorities existed still count.
+func monitorPriorityWeight(monitor Monitor) float64 {
+	if monitor.Priority <= 0 {
+		return 1
+	}
+	return float64(monitor.Priority)
+}
+
+// computeHealthScore combines availability and latency trend into a 0-100
+// score. Availability is the priority-weighted share of healthy monitors,
+// with degraded monitors counting as half healthy and unknown monitors
+// excluded. The latency factor compares each monitor's last response time
+// with its smoothed average: a stable or improving monitor scores 1 and a
+// monitor responding twice as slowly as usual scores 0.5.
+func computeHealthScore(monitors []Monitor, weights healthScoreWeights) healthScore {
+	result := healthScore{Monitors: len(monitors), Weights: weights, AvailabilityScore: 1, LatencyScore: 1}
+
+	var healthyWeight, totalWeight float64
+	var latencyWeight, latencyTotal float64
+	for _, monitor := range monitors {
+		weight := monitorPriorityWeight(monitor)
+		switch strings.ToUpper(monitor.Status) {
+		case statusHealthy:
+			healthyWeight += weight
+		case statusDegraded:
+			healthyWeight += weight / 2
+		case statusUnhealthy:
+		default:
+			continue
+		}
+		totalWeight += weight
+		result.ScoredMonitors++
+
+		if monitor.SmoothedResponseTimeMs > 0 && monitor.LastResponseTimeMs > 0 {
+			ratio := monitor.SmoothedResponseTimeMs / float64(monitor.LastResponseTimeMs)
+			latencyTotal += weight * math.Min(1, ratio)
+			latencyWeight += weight
+			result.LatencySamples++
+		}
+	}
+
+	if totalWeight > 0 {
+		result.AvailabilityScore = roundScore(healthyWeight / totalWeight)
+	}
+	if latencyWeight > 0 {
+		result.LatencyScore = roundScore(latencyTotal / latencyWeight)
+	}
+	result.TotalPriorityWeight = totalWeight
+	if result.ScoredMonitors == 0 {
+		return result
+	}
+
+	combined := (weights.Availability*result.AvailabilityScore + weights.Latency*result.LatencyScore) /
+		(weights.Availability + weights.Latency)
+	result.Score = math.Round(combined * 100)
+	return result
+}
+
+func roundScore(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
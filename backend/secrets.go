@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// secretEnvPrefix scopes environment lookups so a monitor URL cannot pull
+// arbitrary process settings such as ADMIN_KEY into an outbound request.
+const secretEnvPrefix = "SECRET_"
+
+var secretPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// minRedactedSecretLength is the shortest secret value that is redacted;
+// shorter ones such as "1" would rewrite unrelated text.
+const minRedactedSecretLength = 4
+
+// secretStore resolves ${NAME} placeholders in monitor URLs at check time.
+// Values come from SECRETS_FILE first, then SECRET_<NAME> environment
+// variables. Resolved values are never persisted.
+type secretStore struct {
+	file map[string]string
+	// redactor replaces secret values with their placeholders. It is built
+	// once at load time, since redact runs on every log attribute.
+	redactor *strings.Replacer
+}
+
+func loadSecretStore(path string) (*secretStore, error) {
+	store := &secretStore{file: map[string]string{}}
+	if path != "" {
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		store.file = values
+	}
+	store.redactor = newSecretRedactor(store.values())
+	return store, nil
+}
+
+// newSecretRedactor maps every secret value of at least
+// minRedactedSecretLength to its placeholder. Longer values come first, and
+// equal lengths are ordered by name, so a value containing another is
+// replaced whole and the result is the same on every run.
+func newSecretRedactor(values map[string]string) *strings.Replacer {
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		if len(value) >= minRedactedSecretLength {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(values[names[i]]) != len(values[names[j]]) {
+			return len(values[names[i]]) > len(values[names[j]])
+		}
+		return names[i] < names[j]
+	})
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, values[name], "${"+name+"}")
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+func (s *secretStore) lookup(name string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	if value, ok := s.file[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(secretEnvPrefix + name)
+}
+
+// expand replaces every placeholder in raw with its secret value. Unknown
+// names are an error rather than being sent literally.
+func (s *secretStore) expand(raw string) (string, error) {
+	var missing string
+	expanded := secretPlaceholder.ReplaceAllStringFunc(raw, func(match string) string {
+		name := secretPlaceholder.FindStringSubmatch(match)[1]
+		value, ok := s.lookup(name)
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return match
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("unknown secret %q", missing)
+	}
+	return expanded, nil
+}
+
+// redact replaces every known secret value in text with its placeholder.
+func (s *secretStore) redact(text string) string {
+	if s == nil || s.redactor == nil || text == "" {
+		return text
+	}
+	return s.redactor.Replace(text)
+}
+
+func (s *secretStore) values() map[string]string {
+	values := map[string]string{}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok && strings.HasPrefix(key, secretEnvPrefix) {
+			values[strings.TrimPrefix(key, secretEnvPrefix)] = value
+		}
+	}
+	for name, value := range s.file {
+		values[name] = value
+	}
+	return values
+}
+
+// redactUpdate scrubs secret values from the error text a check is about
+// to persist; transport errors usually quote the full request URL.
+func (s *secretStore) redactUpdate(update map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	if message, ok := update["last_error"].(string); ok {
+		update["last_error"] = s.redact(message)
+	}
+	if results, ok := update["ip_results"].(ipResultList); ok {
+		for i := range results {
+			results[i].Error = s.redact(results[i].Error)
+		}
+	}
+}
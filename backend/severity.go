@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notification severities. The first four are PagerDuty's; severityLog only
+// writes the notification to the log.
+const (
+	severityCritical = "critical"
+	severityError    = "error"
+	severityWarning  = "warning"
+	severityInfo     = "info"
+	severityLog      = "log"
+)
+
+var notificationSeverities = []string{severityCritical, severityError, severityWarning, severityInfo, severityLog}
+
+// maxScheduleWindows bounds notification_schedule.
+const maxScheduleWindows = 50
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// severityWindow gives failures starting between Start and End (HH:MM, End
+// exclusive) on the listed days a severity. A window whose End is not after
+// its Start runs past midnight and belongs to the day it starts on. No days
+// means every day.
+type severityWindow struct {
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Severity string   `json:"severity"`
+}
+
+// severitySchedule is persisted as a JSON text column.
+type severitySchedule []severityWindow
+
+func (s severitySchedule) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(s)
+	return string(encoded), err
+}
+
+func (s *severitySchedule) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported severitySchedule value %T", value)
+	}
+	if len(raw) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+func (severitySchedule) GormDataType() string {
+	return "text"
+}
+
+// parseClock parses an HH:MM time of day into minutes after midnight.
+func parseClock(raw string) (int, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", raw)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// normalizeSeveritySchedule validates a notification_schedule and its
+// timezone, returning the schedule with days and severities lower-cased.
+func normalizeSeveritySchedule(windows []severityWindow, timezone string) (severitySchedule, error) {
+	if len(windows) > maxScheduleWindows {
+		return nil, fmt.Errorf("notification_schedule cannot list more than %d windows", maxScheduleWindows)
+	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("notification_timezone %q is not a known time zone", timezone)
+		}
+	}
+	var schedule severitySchedule
+	for i, window := range windows {
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("notification_schedule window %d: start %v", i+1, err)
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return nil, fmt.Errorf("notification_schedule window %d: end %v", i+1, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("notification_schedule window %d is empty", i+1)
+		}
+		normalized := severityWindow{Start: strings.TrimSpace(window.Start), End: strings.TrimSpace(window.End)}
+		normalized.Severity = strings.ToLower(strings.TrimSpace(window.Severity))
+		if !containsString(notificationSeverities, normalized.Severity) {
+			return nil, fmt.Errorf("notification_schedule window %d: severity must be one of %s", i+1, strings.Join(notificationSeverities, ", "))
+		}
+		for _, day := range window.Days {
+			day = strings.ToLower(strings.TrimSpace(day))
+			if len(day) > 3 {
+				day = day[:3]
+			}
+			if !containsString(weekdayNames, day) {
+				return nil, fmt.Errorf("notification_schedule window %d: unknown day %q", i+1, day)
+			}
+			normalized.Days = append(normalized.Days, day)
+		}
+		schedule = append(schedule, normalized)
+	}
+	return schedule, nil
+}
+
+// onDay reports whether the window applies to failures starting on day.
+func (w severityWindow) onDay(day time.Weekday) bool {
+	return len(w.Days) == 0 || containsString(w.Days, weekdayNames[day])
+}
+
+// covers reports whether t falls within the window.
+func (w severityWindow) covers(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return minute >= start && minute < end && w.onDay(t.Weekday())
+	}
+	if minute >= start {
+		return w.onDay(t.Weekday())
+	}
+	return minute < end && w.onDay(t.AddDate(0, 0, -1).Weekday())
+}
+
+// parseQuietHours parses QUIET_HOURS, a window such as "22:00-07:00" during
+// which DEGRADED notifications are held.
+func parseQuietHours(raw string) (*severityWindow, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	start, end, ok := strings.Cut(raw, "-")
+	if !ok {
+		return nil, fmt.Errorf("%q is not a range such as 22:00-07:00", raw)
+	}
+	schedule, err := normalizeSeveritySchedule([]severityWindow{{Start: start, End: end, Severity: severityLog}}, "")
+	if err != nil {
+		return nil, err
+	}
+	return &schedule[0], nil
+}
+
+// notificationSeverity returns how urgently a monitor turning status at now
+// is reported. The first window of the monitor's notification_schedule that
+// covers now decides, in the monitor's notification_timezone or else the
+// server's local time. Monitors without a schedule hold DEGRADED
+// notifications during the global quiet hours, but an UNHEALTHY one still
+// pages. In every other case UNHEALTHY is critical and DEGRADED a warning.
+func (mc *monitorChecker) notificationSeverity(monitor *Monitor, status string, now time.Time) string {
+	if len(monitor.NotificationSchedule) > 0 {
+		if monitor.NotificationTimezone != "" {
+			if location, err := time.LoadLocation(monitor.NotificationTimezone); err == nil {
+				now = now.In(location)
+			}
+		}
+		for _, window := range monitor.NotificationSchedule {
+			if window.covers(now) {
+				return window.Severity
+			}
+		}
+	} else if status != statusUnhealthy && mc.quietHours != nil && mc.quietHours.covers(now) {
+		return severityLog
+	}
+	if status == statusDegraded {
+		return severityWarning
+	}
+	return severityCritical
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// slackAttempts bounds deliveries of one message when Slack answers with a
+// rate limit or server error.
+const slackAttempts = 3
+
+// Slack attachment colors.
+const (
+	slackColorDanger  = "danger"
+	slackColorWarning = "warning"
+	slackColorGood    = "good"
+)
+
+// slackMessage is an incoming-webhook request body.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text,omitempty"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Fallback string       `json:"fallback"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackNotifier posts a message to a Slack incoming webhook when a monitor
+// starts failing, moves between failing states, or recovers. A nil notifier
+// does nothing.
+type slackNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func newSlackNotifier(url string) *slackNotifier {
+	if url == "" {
+		return nil
+	}
+	return &slackNotifier{client: &http.Client{Timeout: 10 * time.Second}, url: url}
+}
+
+// message returns the message for a monitor moving from previous to status,
+// or false when the change needs none.
+func (n *slackNotifier) message(monitor *Monitor, previous, status string, update map[string]interface{}) (slackMessage, bool) {
+	if n == nil {
+		return slackMessage{}, false
+	}
+	var color string
+	switch {
+	case status == statusUnhealthy:
+		color = slackColorDanger
+	case status == statusDegraded:
+		color = slackColorWarning
+	case status == statusHealthy && isFailing(previous):
+		color = slackColorGood
+	default:
+		return slackMessage{}, false
+	}
+	transition := fmt.Sprintf("%s → %s", previous, status)
+	code, _ := update["last_response_code"].(int)
+	latency, _ := update["last_response_time_ms"].(int)
+	responseCode := "none"
+	if code != 0 {
+		responseCode = fmt.Sprintf("%d", code)
+	}
+	attachment := slackAttachment{
+		Color: color,
+		Title: fmt.Sprintf("%s is %s", monitor.Name, status),
+		Fields: []slackField{
+			{Title: "Transition", Value: transition, Short: true},
+			{Title: "Response code", Value: responseCode, Short: true},
+			{Title: "Latency", Value: fmt.Sprintf("%d ms", latency), Short: true},
+			{Title: "URL", Value: monitor.redactedURL(), Short: false},
+		},
+		Fallback: fmt.Sprintf("%s: %s", monitor.Name, transition),
+		Ts:       time.Now().Unix(),
+	}
+	if lastError, _ := update["last_error"].(string); lastError != "" && isFailing(status) {
+		attachment.Text = lastError
+	}
+	if monitor.FailureMessage != "" && isFailing(status) {
+		attachment.Fields = append(attachment.Fields, slackField{Title: "Note", Value: monitor.FailureMessage})
+	}
+	return slackMessage{Text: attachment.Fallback, Attachments: []slackAttachment{attachment}}, true
+}
+
+// notice returns a message about the service itself rather than a monitor,
+// or false when the notifier is disabled.
+func (n *slackNotifier) notice(color, title, text string) (slackMessage, bool) {
+	if n == nil {
+		return slackMessage{}, false
+	}
+	attachment := slackAttachment{Color: color, Title: title, Text: text, Fallback: title, Ts: time.Now().Unix()}
+	return slackMessage{Text: title, Attachments: []slackAttachment{attachment}}, true
+}
+
+// send delivers message, retrying rate limits and server errors.
+func (n *slackNotifier) send(monitorID uint, message slackMessage) {
+	if err := n.deliver(message); err != nil {
+		slog.Error("slack message failed", "monitor_id", monitorID, "error", err)
+	}
+}
+
+func (n *slackNotifier) deliver(message slackMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return postWithRetries(n.client, n.url, body, nil, slackAttempts)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// statusCodeRange is an inclusive range of HTTP status codes.
+type statusCodeRange struct {
+	from, to int
+}
+
+// parseStatusCodes parses an expected_status_codes value: comma-separated
+// codes and ranges such as "200-299,401". It returns the ranges and the
+// value in canonical form, without spaces.
+func parseStatusCodes(raw string) ([]statusCodeRange, string, error) {
+	var ranges []statusCodeRange
+	var parts []string
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, "", fmt.Errorf("empty entry in %q", raw)
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		r := statusCodeRange{}
+		var err error
+		if r.from, err = parseStatusCode(from); err != nil {
+			return nil, "", err
+		}
+		r.to = r.from
+		if isRange {
+			if r.to, err = parseStatusCode(to); err != nil {
+				return nil, "", err
+			}
+			if r.to < r.from {
+				return nil, "", fmt.Errorf("range %s is reversed", part)
+			}
+		}
+		ranges = append(ranges, r)
+		if r.from == r.to {
+			parts = append(parts, strconv.Itoa(r.from))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.from, r.to))
+		}
+	}
+	return ranges, strings.Join(parts, ","), nil
+}
+
+func parseStatusCode(raw string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("%q is not a status code between 100 and 599", strings.TrimSpace(raw))
+	}
+	return code, nil
+}
+
+// normalizeStatusCodes validates an expected_status_codes value and returns
+// its canonical form; an empty value clears the setting.
+func normalizeStatusCodes(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	_, canonical, err := parseStatusCodes(raw)
+	if err != nil {
+		return "", fmt.Errorf("Invalid expected_status_codes: %v", err)
+	}
+	return canonical, nil
+}
+
+// expectedCode reports whether a response code counts as a success for the
+// monitor: one of its expected_status_codes, or any 2xx/3xx when none are
+// set.
+func (m *Monitor) expectedCode(code int) bool {
+	if m.ExpectedStatusCodes == "" {
+		return code >= 200 && code < 400
+	}
+	ranges, _, err := parseStatusCodes(m.ExpectedStatusCodes)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
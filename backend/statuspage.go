@@ -0,0 +1,121 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+h1 { font-size: 1.5rem; }
+.banner { padding: 1rem; border-radius: .5rem; color: #fff; font-weight: 600; margin-bottom: 1.5rem; }
+ul { list-style: none; padding: 0; border: 1px solid #d0d7de; border-radius: .5rem; }
+li { display: flex; justify-content: space-between; padding: .75rem 1rem; border-top: 1px solid #d0d7de; }
+li:first-child { border-top: none; }
+.badge { font-size: .85rem; font-weight: 600; }
+.HEALTHY { background: #1a7f37; } .badge.HEALTHY { background: none; color: #1a7f37; }
+.DEGRADED { background: #bf8700; } .badge.DEGRADED { background: none; color: #bf8700; }
+.UNHEALTHY { background: #cf222e; } .badge.UNHEALTHY { background: none; color: #cf222e; }
+.UNKNOWN { background: #6e7781; } .badge.UNKNOWN { background: none; color: #6e7781; }
+footer { margin-top: 1rem; font-size: .8rem; color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="banner {{.Status}}">{{.Summary}}</div>
+{{if .Monitors}}<ul>
+{{range .Monitors}}<li><span>{{.Name}}</span><span class="badge {{.Status}}">{{.Status}}</span></li>
+{{end}}</ul>{{else}}<p>No monitors are published on this page.</p>{{end}}
+<footer>Updated {{.Updated}} &middot; refreshes every {{.Refresh}} seconds</footer>
+</body>
+</html>
+`))
+
+type statusPageMonitor struct {
+	Name   string
+	Status string
+}
+
+type statusPageData struct {
+	Title    string
+	Status   string
+	Summary  string
+	Monitors []statusPageMonitor
+	Updated  string
+	Refresh  int
+}
+
+var statusPageSummaries = map[string]string{
+	statusHealthy:   "All systems operational",
+	statusDegraded:  "Some systems are experiencing issues",
+	statusUnhealthy: "Major outage",
+	statusUnknown:   "Status unknown",
+}
+
+// registerStatusPageRoutes serves a self-contained HTML status page. Without
+// a key, or with a public key, it lists only monitors flagged public; with a
+// read or admin key, passed as the Authorization header or the key query
+// parameter, it lists every monitor of that key's owner.
+func registerStatusPageRoutes(router *gin.Engine, db *gorm.DB, keys apiKeys, title string, refresh int) {
+	router.GET("/status/page", func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Authorization"))
+		if key == "" {
+			key = strings.TrimSpace(c.Query("key"))
+		}
+
+		query := db.Model(&Monitor{})
+		switch {
+		case key == "" || keys.public[key]:
+			query = query.Where("public = ?", true)
+		case keys.superadmin != "" && key == keys.superadmin:
+		default:
+			owner, ok := keys.admin[key]
+			if !ok {
+				owner, ok = keys.read[key]
+			}
+			if !ok {
+				c.String(http.StatusForbidden, "Forbidden")
+				return
+			}
+			query = query.Where("owner_key = ?", owner)
+		}
+
+		var monitors []Monitor
+		if err := query.Order("name asc").Find(&monitors).Error; err != nil {
+			c.String(http.StatusInternalServerError, "Failed to load status")
+			return
+		}
+
+		data := statusPageData{
+			Title:    title,
+			Monitors: make([]statusPageMonitor, len(monitors)),
+			Updated:  time.Now().UTC().Format("2006-01-02 15:04 MST"),
+			Refresh:  refresh,
+		}
+		statuses := make([]string, len(monitors))
+		for i, monitor := range monitors {
+			statuses[i] = strings.ToUpper(monitor.Status)
+			data.Monitors[i] = statusPageMonitor{Name: monitor.Name, Status: statuses[i]}
+		}
+		data.Status = rollupStatus(statuses)
+		data.Summary = statusPageSummaries[data.Status]
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Cache-Control", "no-store")
+		if err := statusPageTemplate.Execute(c.Writer, data); err != nil {
+			c.Error(err)
+		}
+	})
+}
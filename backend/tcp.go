@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const monitorTypeTCP = "tcp"
+
+// checkTCP opens a connection to the host:port in a tcp:// monitor URL. A
+// completed handshake is HEALTHY and its duration is the response time.
+// With expect_closed the verdict is inverted; see expectClosedResult.
+func (mc *monitorChecker) checkTCP(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	update := map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            "",
+		"last_error_category":   "",
+	}
+
+	address, err := socketAddress(monitor.URL, monitorTypeTCP)
+	if err == nil {
+		var dialer net.Dialer
+		start := time.Now()
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		if monitor.ExpectClosed {
+			update["last_response_time_ms"] = int(time.Since(start) / time.Millisecond)
+			return expectClosedResult(monitor, address, err, update)
+		}
+		if err == nil {
+			update["last_response_time_ms"] = int(time.Since(start) / time.Millisecond)
+		}
+	}
+	if err != nil {
+		slog.Error("tcp connect failed", "monitor_id", monitor.ID, "error", err)
+		update["last_error"] = err.Error()
+		update["last_error_category"] = classifyRequestError(err)
+		return update
+	}
+
+	update["status"] = statusHealthy
+	return update
+}
+
+// expectClosedResult judges a connection attempt to a port that should be
+// closed. A refusal is HEALTHY and a completed handshake UNHEALTHY. A
+// timeout is only DEGRADED: a firewall dropping packets looks the same as an
+// unreachable host, so the port cannot be confirmed closed.
+func expectClosedResult(monitor *Monitor, address string, err error, update map[string]interface{}) map[string]interface{} {
+	if err == nil {
+		update["last_error"] = fmt.Sprintf("%s accepted a connection but is expected to be closed", address)
+		return update
+	}
+	category := classifyRequestError(err)
+	switch category {
+	case errorCategoryRefused:
+		update["status"] = statusHealthy
+	case errorCategoryTimeout:
+		update["status"] = statusDegraded
+		update["last_error"] = fmt.Sprintf("%s did not answer; it may be filtered or the host unreachable: %v", address, err)
+		update["last_error_category"] = category
+	default:
+		slog.Error("tcp connect failed", "monitor_id", monitor.ID, "error", err)
+		update["last_error"] = err.Error()
+		update["last_error_category"] = category
+	}
+	return update
+}
+
+// socketAddress extracts host:port from a scheme://host:port monitor URL.
+func socketAddress(raw, scheme string) (string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(parsed.Scheme, scheme) || parsed.Hostname() == "" || parsed.Port() == "" {
+		return "", fmt.Errorf("%s monitors need a %s://host:port URL", scheme, scheme)
+	}
+	return parsed.Host, nil
+}
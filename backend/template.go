@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MonitorTemplate describes a family of monitors that differ only by
+// placeholder values, e.g. a URL of "https://{{host}}/health".
+type MonitorTemplate struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null"`
+	Type     string `json:"type" gorm:"not null"`
+	URL      string `json:"url" gorm:"not null"`
+	OwnerKey string `json:"-" gorm:"index"`
+}
+
+// templateRequest captures the fields of a template. On update every field
+// is optional.
+type templateRequest struct {
+	Name *string `json:"name"`
+	Type *string `json:"type"`
+	URL  *string `json:"url"`
+}
+
+// fromTemplateRequest instantiates one monitor per variable set.
+type fromTemplateRequest struct {
+	TemplateID uint                `json:"template_id" binding:"required"`
+	Variables  []map[string]string `json:"variables" binding:"required"`
+}
+
+// stringMap is a string-to-string map persisted as a JSON text column.
+type stringMap map[string]string
+
+func (m stringMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(m)
+	return string(encoded), err
+}
+
+func (m *stringMap) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported stringMap value %T", value)
+	}
+	if len(raw) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(raw, m)
+}
+
+func (stringMap) GormDataType() string {
+	return "text"
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// renderTemplate substitutes every {{name}} placeholder in pattern and fails
+// if a placeholder has no value.
+func renderTemplate(pattern string, vars map[string]string) (string, error) {
+	var missing []string
+	rendered := templatePlaceholder.ReplaceAllStringFunc(pattern, func(match string) string {
+		name := templatePlaceholder.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing variables: %s", strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
+
+// applyTemplate fills a monitor's name, type, and URL from the template.
+func applyTemplate(monitor *Monitor, tmpl MonitorTemplate, vars map[string]string) error {
+	name, err := renderTemplate(tmpl.Name, vars)
+	if err != nil {
+		return err
+	}
+	typeValue, err := renderTemplate(tmpl.Type, vars)
+	if err != nil {
+		return err
+	}
+	urlValue, err := renderTemplate(tmpl.URL, vars)
+	if err != nil {
+		return err
+	}
+	if err := validateMonitorType(strings.TrimSpace(typeValue)); err != nil {
+		return err
+	}
+	if err := validateTarget(typeValue, urlValue); err != nil {
+		return fmt.Errorf("invalid URL %q", urlValue)
+	}
+	monitor.Name = strings.TrimSpace(name)
+	monitor.Type = strings.TrimSpace(typeValue)
+	monitor.URL = strings.TrimSpace(urlValue)
+	monitor.TemplateVars = stringMap(vars)
+	return nil
+}
+
+// ownedTemplates scopes a template query to the caller, like ownedMonitors.
+func ownedTemplates(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if c.GetBool(superadminContextKey) {
+		return db.Model(&MonitorTemplate{})
+	}
+	if c.GetBool(publicContextKey) {
+		// Public keys own nothing, so they see none.
+		return db.Model(&MonitorTemplate{}).Where("1 = 0")
+	}
+	return db.Model(&MonitorTemplate{}).Where("owner_key = ?", c.GetString(ownerContextKey))
+}
+
+func registerTemplateRoutes(router *gin.Engine, db *gorm.DB, checker *monitorChecker, keys apiKeys) {
+	router.GET("/template", authorize(keys, true), func(c *gin.Context) {
+		var templates []MonitorTemplate
+		if err := ownedTemplates(c, db).Order("id asc").Find(&templates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch templates"})
+			return
+		}
+		c.JSON(http.StatusOK, templates)
+	})
+
+	router.POST("/template", authorize(keys, false), func(c *gin.Context) {
+		var req templateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+		if req.Name == nil || req.Type == nil || req.URL == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Name, type, and url are required"})
+			return
+		}
+
+		tmpl := MonitorTemplate{
+			Name:     strings.TrimSpace(*req.Name),
+			Type:     strings.TrimSpace(*req.Type),
+			URL:      strings.TrimSpace(*req.URL),
+			OwnerKey: c.GetString(ownerContextKey),
+		}
+		if tmpl.Name == "" || tmpl.Type == "" || tmpl.URL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Name, type, and url are required"})
+			return
+		}
+
+		if err := db.Create(&tmpl).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create template"})
+			return
+		}
+		c.JSON(http.StatusCreated, tmpl)
+	})
+
+	router.PUT("/template/:id", authorize(keys, false), func(c *gin.Context) {
+		var req templateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+
+		var tmpl MonitorTemplate
+		if err := ownedTemplates(c, db).First(&tmpl, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+		for _, field := range []struct {
+			value  *string
+			target *string
+			label  string
+		}{
+			{req.Name, &tmpl.Name, "Name"},
+			{req.Type, &tmpl.Type, "Type"},
+			{req.URL, &tmpl.URL, "URL"},
+		} {
+			if field.value == nil {
+				continue
+			}
+			trimmed := strings.TrimSpace(*field.value)
+			if trimmed == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": field.label + " cannot be empty"})
+				return
+			}
+			*field.target = trimmed
+		}
+
+		propagate := c.Query("propagate") == "true"
+		var instances []Monitor
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&tmpl).Error; err != nil {
+				return err
+			}
+			if !propagate {
+				return nil
+			}
+			if err := tx.Where("template_id = ?", tmpl.ID).Find(&instances).Error; err != nil {
+				return err
+			}
+			for i := range instances {
+				if err := applyTemplate(&instances[i], tmpl, instances[i].TemplateVars); err != nil {
+					return templateError{monitorID: instances[i].ID, err: err}
+				}
+				if err := tx.Save(&instances[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		var renderErr templateError
+		if errors.As(err, &renderErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": renderErr.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update template"})
+			return
+		}
+
+		for _, monitor := range instances {
+			checker.triggerCheck(monitor.ID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"template": tmpl, "updated_monitors": len(instances)})
+	})
+
+	router.DELETE("/template/:id", authorize(keys, false), func(c *gin.Context) {
+		var tmpl MonitorTemplate
+		if err := ownedTemplates(c, db).First(&tmpl, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&Monitor{}).Where("template_id = ?", tmpl.ID).Update("template_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&tmpl).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete template"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+	})
+
+	router.POST("/monitor/from-template", authorize(keys, false), func(c *gin.Context) {
+		var req fromTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+		if len(req.Variables) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "At least one variable set is required"})
+			return
+		}
+
+		var tmpl MonitorTemplate
+		if err := ownedTemplates(c, db).First(&tmpl, req.TemplateID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+
+		monitors := make([]Monitor, len(req.Variables))
+		for i, vars := range req.Variables {
+			templateID := tmpl.ID
+			monitors[i] = Monitor{
+				Status:     statusUnknown,
+				TemplateID: &templateID,
+				OwnerKey:   c.GetString(ownerContextKey),
+			}
+			if err := applyTemplate(&monitors[i], tmpl, vars); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("variables[%d]: %v", i, err)})
+				return
+			}
+		}
+
+		if err := db.Create(&monitors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create monitors"})
+			return
+		}
+
+		for _, monitor := range monitors {
+			checker.triggerCheck(monitor.ID)
+		}
+
+		c.JSON(http.StatusCreated, monitors)
+	})
+}
+
+// templateError reports which instance could not be re-rendered.
+type templateError struct {
+	monitorID uint
+	err       error
+}
+
+func (e templateError) Error() string {
+	return fmt.Sprintf("monitor %d: %v", e.monitorID, e.err)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// loadThrottle skips scheduled batches while the process itself is under
+// heavy load, so the monitor does not make a constrained host worse. A nil
+// throttle never engages.
+type loadThrottle struct {
+	maxGoroutines int
+	maxCPUPercent float64
+
+	mu        sync.Mutex
+	lastCPU   time.Duration
+	lastWall  time.Time
+	throttled bool
+	reason    string
+}
+
+func newLoadThrottle(maxGoroutines int, maxCPUPercent float64) *loadThrottle {
+	if maxGoroutines <= 0 && maxCPUPercent <= 0 {
+		return nil
+	}
+	t := &loadThrottle{maxGoroutines: maxGoroutines, maxCPUPercent: maxCPUPercent}
+	t.lastCPU, t.lastWall = processCPUTime(), time.Now()
+	return t
+}
+
+// engage samples the current load and reports whether the next batch should
+// be skipped.
+func (t *loadThrottle) engage() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reason := ""
+	if goroutines := runtime.NumGoroutine(); t.maxGoroutines > 0 && goroutines > t.maxGoroutines {
+		reason = fmt.Sprintf("%d goroutines exceeds limit of %d", goroutines, t.maxGoroutines)
+	}
+
+	cpu, now := processCPUTime(), time.Now()
+	// Usage is the process's CPU time over the wall time it had on every
+	// processor the runtime may use.
+	if available := now.Sub(t.lastWall) * time.Duration(runtime.GOMAXPROCS(0)); available > 0 && t.maxCPUPercent > 0 && reason == "" {
+		usage := float64(cpu-t.lastCPU) / float64(available) * 100
+		if usage > t.maxCPUPercent {
+			reason = fmt.Sprintf("CPU usage %.1f%% exceeds limit of %.1f%%", usage, t.maxCPUPercent)
+		}
+	}
+	t.lastCPU, t.lastWall = cpu, now
+
+	if throttled := reason != ""; throttled && !t.throttled {
+		slog.Warn("checker throttled, skipping batches", "reason", reason)
+	} else if !throttled && t.throttled {
+		slog.Info("checker throttle released")
+	}
+	t.throttled = reason != ""
+	t.reason = reason
+	return t.throttled
+}
+
+// state reports the most recent throttle decision for /healthz.
+func (t *loadThrottle) state() (bool, string) {
+	if t == nil {
+		return false, ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.throttled, t.reason
+}
+
+// processCPUTime returns the user and system CPU time the process has used,
+// or zero when the operating system does not report it.
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
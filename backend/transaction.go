@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const monitorTypeTransaction = "transaction"
+
+// maxTransactionSteps bounds the steps of a transaction monitor.
+const maxTransactionSteps = 20
+
+var variableName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// transactionStep is one request of a transaction monitor. Its URL may be
+// relative to the monitor's url. URL, header values, and body may use
+// {{name}} placeholders for values extracted by earlier steps, and ${NAME}
+// secret placeholders.
+type transactionStep struct {
+	Name                string            `json:"name,omitempty"`
+	Method              string            `json:"method,omitempty"`
+	URL                 string            `json:"url"`
+	Headers             map[string]string `json:"headers,omitempty"`
+	Body                string            `json:"body,omitempty"`
+	ExpectedStatusCodes string            `json:"expected_status_codes,omitempty"`
+	ExpectedKeyword     string            `json:"expected_keyword,omitempty"`
+	// Extract maps a variable name to the JSON path of a value in the
+	// response body, for use by later steps.
+	Extract map[string]string `json:"extract,omitempty"`
+}
+
+// label names the step in errors and history: its name, or its position.
+func (s transactionStep) label(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("step %d", index+1)
+}
+
+// transactionSteps is persisted as a JSON text column.
+type transactionSteps []transactionStep
+
+func (s transactionSteps) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(s)
+	return string(encoded), err
+}
+
+func (s *transactionSteps) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported transactionSteps value %T", value)
+	}
+	if len(raw) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+func (transactionSteps) GormDataType() string {
+	return "text"
+}
+
+// normalizeSteps validates the steps of a monitor of typeValue and returns
+// them with methods and status codes in canonical form. Only transaction
+// monitors have steps, and they need at least one.
+func normalizeSteps(typeValue string, steps []transactionStep) (transactionSteps, error) {
+	if !strings.EqualFold(strings.TrimSpace(typeValue), monitorTypeTransaction) {
+		if len(steps) > 0 {
+			return nil, fmt.Errorf("steps require type %s", monitorTypeTransaction)
+		}
+		return nil, nil
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("a %s monitor needs at least one step", monitorTypeTransaction)
+	}
+	if len(steps) > maxTransactionSteps {
+		return nil, fmt.Errorf("steps cannot list more than %d steps", maxTransactionSteps)
+	}
+	normalized := make(transactionSteps, 0, len(steps))
+	for i, step := range steps {
+		step.Name = strings.TrimSpace(step.Name)
+		label := step.label(i)
+		step.URL = strings.TrimSpace(step.URL)
+		if _, err := url.Parse(step.URL); err != nil || step.URL == "" {
+			return nil, fmt.Errorf("%s: invalid url %q", label, step.URL)
+		}
+		method, err := normalizeMethod(step.Method)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", label, err)
+		}
+		step.Method = method
+		if err := validateRequestBody(method, step.Body); err != nil {
+			return nil, fmt.Errorf("%s: %v", label, strings.Replace(err.Error(), "request_body", "body", 1))
+		}
+		if err := validateRequestHeaders(step.Headers); err != nil {
+			return nil, fmt.Errorf("%s: %v", label, err)
+		}
+		if step.ExpectedStatusCodes, err = normalizeStatusCodes(step.ExpectedStatusCodes); err != nil {
+			return nil, fmt.Errorf("%s: %v", label, err)
+		}
+		for name, path := range step.Extract {
+			if !variableName.MatchString(name) {
+				return nil, fmt.Errorf("%s: invalid variable name %q", label, name)
+			}
+			if strings.TrimSpace(path) == "" {
+				return nil, fmt.Errorf("%s: variable %s needs a JSON path", label, name)
+			}
+		}
+		normalized = append(normalized, step)
+	}
+	return normalized, nil
+}
+
+// transactionFailure describes the step a transaction stopped at. Failed
+// assertions have no error category.
+type transactionFailure struct {
+	step     string
+	message  string
+	category string
+}
+
+// checkTransaction runs the steps of a transaction monitor in order, sharing
+// cookies between them, and stops at the first step that fails. The monitor
+// is HEALTHY only when every step passes; the failing step is recorded in
+// last_failed_step. The response time is the total of all steps run.
+func (mc *monitorChecker) checkTransaction(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	update := map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            "",
+		"last_error_category":   "",
+		"last_failed_step":      "",
+	}
+	base, err := url.Parse(monitor.URL)
+	if err != nil {
+		update["last_error"] = err.Error()
+		update["last_error_category"] = errorCategoryOther
+		return update
+	}
+	client, release := mc.clientFor(monitor, "")
+	defer release()
+	jar, _ := cookiejar.New(nil)
+	withJar := *client
+	withJar.Jar = jar
+
+	vars := map[string]string{}
+	total, sent, received := 0, 0, 0
+	var failure *transactionFailure
+	for i, step := range monitor.Steps {
+		code, latency, bytesSent, bytesReceived, stepFailure := mc.runStep(ctx, &withJar, base, step, vars)
+		total += latency
+		sent += bytesSent
+		received += bytesReceived
+		update["last_response_code"] = code
+		if stepFailure != nil {
+			stepFailure.step = step.label(i)
+			failure = stepFailure
+			break
+		}
+	}
+	update["last_response_time_ms"] = total
+	update["last_bytes_sent"] = sent
+	update["last_bytes_received"] = received
+	if failure != nil {
+		slog.Error("transaction failed", "monitor_id", monitor.ID, "step", failure.step, "error", failure.message)
+		update["last_error"] = fmt.Sprintf("%s: %s", failure.step, failure.message)
+		update["last_error_category"] = failure.category
+		update["last_failed_step"] = failure.step
+		return update
+	}
+	update["status"] = statusHealthy
+	if monitor.DegradedThresholdMs > 0 && total > monitor.DegradedThresholdMs {
+		update["status"] = statusDegraded
+		update["last_error"] = fmt.Sprintf("transaction took %d ms, above the %d ms threshold", total, monitor.DegradedThresholdMs)
+	}
+	return update
+}
+
+// runStep sends one step's request and applies its assertions, storing any
+// extracted values in vars. It returns the response code, latency, and
+// estimated traffic, and the failure if the step did not pass.
+func (mc *monitorChecker) runStep(ctx context.Context, client *http.Client, base *url.URL, step transactionStep, vars map[string]string) (int, int, int, int, *transactionFailure) {
+	// Secrets are filled in first, so an extracted value can never name one.
+	render := func(pattern string) (string, error) {
+		expanded, err := mc.secrets.expand(pattern)
+		if err != nil {
+			return "", err
+		}
+		return renderTemplate(expanded, vars)
+	}
+	rawURL, err := render(step.URL)
+	if err != nil {
+		return 0, 0, 0, 0, &transactionFailure{message: err.Error(), category: errorCategoryOther}
+	}
+	target, err := base.Parse(rawURL)
+	if err != nil {
+		return 0, 0, 0, 0, &transactionFailure{message: err.Error(), category: errorCategoryOther}
+	}
+	body, err := render(step.Body)
+	if err != nil {
+		return 0, 0, 0, 0, &transactionFailure{message: err.Error(), category: errorCategoryOther}
+	}
+	headers := make(map[string]string, len(step.Headers))
+	for name, value := range step.Headers {
+		if headers[name], err = render(value); err != nil {
+			return 0, 0, 0, 0, &transactionFailure{message: fmt.Sprintf("header %s: %v", name, err), category: errorCategoryOther}
+		}
+	}
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, step.Method, target.String(), reader)
+	if err != nil {
+		return 0, 0, 0, 0, &transactionFailure{message: err.Error(), category: errorCategoryOther}
+	}
+	if body != "" {
+		contentType := "text/plain; charset=utf-8"
+		if json.Valid([]byte(body)) {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	applyRequestHeaders(headers, req)
+
+	bytesSent := requestWireSize(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, int(time.Since(start) / time.Millisecond), bytesSent, 0, &transactionFailure{message: err.Error(), category: classifyRequestError(err)}
+	}
+	counted := &countingReader{ReadCloser: resp.Body}
+	resp.Body = counted
+	content, readErr := readDecodedBody(resp)
+	resp.Body.Close()
+	latency := int(time.Since(start) / time.Millisecond)
+	bytesReceived := responseWireSize(resp, counted.n)
+	code := resp.StatusCode
+
+	expected := Monitor{ExpectedStatusCodes: step.ExpectedStatusCodes}
+	switch {
+	case !expected.expectedCode(code):
+		return code, latency, bytesSent, bytesReceived, &transactionFailure{message: fmt.Sprintf("unexpected status code %d", code)}
+	case readErr != nil:
+		return code, latency, bytesSent, bytesReceived, &transactionFailure{message: fmt.Sprintf("body read failed: %v", readErr)}
+	case step.ExpectedKeyword != "" && !bytes.Contains(content, []byte(step.ExpectedKeyword)):
+		return code, latency, bytesSent, bytesReceived, &transactionFailure{message: fmt.Sprintf("response does not contain %q", step.ExpectedKeyword)}
+	}
+	if len(step.Extract) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return code, latency, bytesSent, bytesReceived, &transactionFailure{message: "response is not JSON, so nothing can be extracted"}
+		}
+		for name, path := range step.Extract {
+			value, ok := lookupJSONPath(doc, path)
+			if !ok || value == nil {
+				return code, latency, bytesSent, bytesReceived, &transactionFailure{message: fmt.Sprintf("%s not found in response", path)}
+			}
+			switch v := value.(type) {
+			case string:
+				vars[name] = v
+			case map[string]interface{}, []interface{}:
+				encoded, _ := json.Marshal(v)
+				vars[name] = string(encoded)
+			default:
+				vars[name] = fmt.Sprint(v)
+			}
+		}
+	}
+	return code, latency, bytesSent, bytesReceived, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// maxBodyReplacePatternLength bounds body_replace_pattern.
+const maxBodyReplacePatternLength = 512
+
+// hasBodyTransform reports whether the monitor preprocesses response bodies.
+func (m *Monitor) hasBodyTransform() bool {
+	return m.BodyStripPrefix != "" || m.BodyStripSuffix != "" || m.BodyReplacePattern != ""
+}
+
+// transformBody prepares a response body for content assertions: it removes
+// body_strip_prefix and body_strip_suffix when present, ignoring surrounding
+// whitespace, then replaces every match of body_replace_pattern with
+// body_replacement, which may refer to groups as $1. Patterns are validated
+// when saved, so a pattern that no longer compiles leaves the body unchanged.
+func transformBody(monitor *Monitor, body []byte) []byte {
+	if !monitor.hasBodyTransform() {
+		return body
+	}
+	if monitor.BodyStripPrefix != "" || monitor.BodyStripSuffix != "" {
+		body = bytes.TrimSpace(body)
+		body = bytes.TrimPrefix(body, []byte(monitor.BodyStripPrefix))
+		body = bytes.TrimSuffix(body, []byte(monitor.BodyStripSuffix))
+	}
+	if monitor.BodyReplacePattern != "" {
+		if re, err := regexp.Compile(monitor.BodyReplacePattern); err == nil {
+			body = re.ReplaceAll(body, []byte(monitor.BodyReplacement))
+		}
+	}
+	return body
+}
+
+// validateBodyReplacePattern checks that pattern compiles.
+func validateBodyReplacePattern(pattern string) error {
+	if len(pattern) > maxBodyReplacePatternLength {
+		return fmt.Errorf("body_replace_pattern must be at most %d characters", maxBodyReplacePatternLength)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid body_replace_pattern: %v", err)
+	}
+	return nil
+}
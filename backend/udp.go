@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+const monitorTypeUDP = "udp"
+
+// udpHexPrefix marks a udp_payload given as hex bytes rather than text.
+const udpHexPrefix = "hex:"
+
+// maxUDPResponseBytes bounds the reply buffer; only the arrival of a reply
+// matters, not its content.
+const maxUDPResponseBytes = 64 * 1024
+
+// checkUDP sends the monitor's payload to the host:port in its udp:// URL and
+// waits for any datagram in reply within the request timeout.
+func (mc *monitorChecker) checkUDP(ctx context.Context, monitor *Monitor) map[string]interface{} {
+	update := map[string]interface{}{
+		"status":                statusUnhealthy,
+		"last_check":            time.Now(),
+		"last_response_code":    0,
+		"last_response_time_ms": 0,
+		"last_error":            "",
+		"last_error_category":   "",
+	}
+
+	address, err := socketAddress(monitor.URL, monitorTypeUDP)
+	if err == nil {
+		var payload []byte
+		payload, err = decodeUDPPayload(monitor.UDPPayload)
+		if err == nil {
+			var rtt time.Duration
+			var received int
+			rtt, received, err = mc.probeUDP(ctx, address, payload)
+			update["last_response_time_ms"] = int(rtt / time.Millisecond)
+			update["last_bytes_sent"] = len(payload)
+			update["last_bytes_received"] = received
+		}
+	}
+	if err != nil {
+		slog.Error("udp probe failed", "monitor_id", monitor.ID, "error", err)
+		update["last_error"] = err.Error()
+		update["last_error_category"] = classifyRequestError(err)
+		return update
+	}
+
+	update["status"] = statusHealthy
+	return update
+}
+
+// probeUDP sends payload and waits for a reply, returning the round trip and
+// the size of the reply.
+func (mc *monitorChecker) probeUDP(ctx context.Context, address string, payload []byte) (time.Duration, int, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		return 0, 0, err
+	}
+	buf := make([]byte, maxUDPResponseBytes)
+	n, err := conn.Read(buf)
+	return time.Since(start), n, err
+}
+
+// decodeUDPPayload returns the bytes to send. A "hex:" prefix decodes the
+// rest as hex so binary protocols such as DNS can be probed.
+func decodeUDPPayload(raw string) ([]byte, error) {
+	if strings.HasPrefix(raw, udpHexPrefix) {
+		payload, err := hex.DecodeString(strings.TrimPrefix(raw, udpHexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex udp_payload")
+		}
+		return payload, nil
+	}
+	return []byte(raw), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is a minimal element tree used to evaluate XPath expressions.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// xpathStep is one location step of the supported XPath subset.
+type xpathStep struct {
+	descendant bool
+	name       string
+	index      int
+	attr       string
+	text       bool
+}
+
+// compileXPath parses the supported subset of XPath: absolute location paths
+// made of element names or "*", optional "//" descendant steps, 1-based
+// positional predicates such as "[2]", and a final "@attr" or "text()" step.
+// Namespace prefixes are ignored.
+func compileXPath(path string) ([]xpathStep, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("xpath must be absolute")
+	}
+	var steps []xpathStep
+	rest := path
+	for rest != "" {
+		step := xpathStep{}
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+		end := strings.Index(rest, "/")
+		if end < 0 {
+			end = len(rest)
+		}
+		token := rest[:end]
+		rest = rest[end:]
+		if token == "" {
+			return nil, fmt.Errorf("empty step in %q", path)
+		}
+
+		switch {
+		case strings.HasPrefix(token, "@"):
+			step.attr = localName(token[1:])
+		case token == "text()":
+			step.text = true
+		default:
+			if open := strings.Index(token, "["); open >= 0 {
+				if !strings.HasSuffix(token, "]") {
+					return nil, fmt.Errorf("unterminated predicate in %q", token)
+				}
+				index, err := strconv.Atoi(token[open+1 : len(token)-1])
+				if err != nil || index < 1 {
+					return nil, fmt.Errorf("unsupported predicate in %q", token)
+				}
+				step.index = index
+				token = token[:open]
+			}
+			step.name = localName(token)
+		}
+		if (step.attr != "" || step.text) && rest != "" {
+			return nil, fmt.Errorf("%q must be the last step", token)
+		}
+		if step.name == "" && step.attr == "" && !step.text {
+			return nil, fmt.Errorf("empty step in %q", path)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// evaluateXPath returns the string value selected by path in an XML body.
+// The boolean is false when the path selects nothing.
+func evaluateXPath(body []byte, path string) (string, bool, error) {
+	steps, err := compileXPath(path)
+	if err != nil {
+		return "", false, err
+	}
+	root, err := parseXMLTree(body)
+	if err != nil {
+		return "", false, err
+	}
+
+	nodes := []*xmlNode{root}
+	for _, step := range steps {
+		if step.attr != "" {
+			for _, node := range nodes {
+				if value, ok := node.attrs[step.attr]; ok {
+					return value, true, nil
+				}
+			}
+			return "", false, nil
+		}
+		if step.text {
+			break
+		}
+		var next []*xmlNode
+		for _, node := range nodes {
+			next = append(next, matchChildren(node, step)...)
+		}
+		if len(next) == 0 {
+			return "", false, nil
+		}
+		nodes = next
+	}
+	return strings.TrimSpace(nodes[0].text.String()), true, nil
+}
+
+func matchChildren(node *xmlNode, step xpathStep) []*xmlNode {
+	var matched []*xmlNode
+	var walk func(*xmlNode)
+	walk = func(parent *xmlNode) {
+		position := 0
+		for _, child := range parent.children {
+			if step.name == "*" || child.name == step.name {
+				position++
+				if step.index == 0 || step.index == position {
+					matched = append(matched, child)
+				}
+			}
+			if step.descendant {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return matched
+}
+
+// parseXMLTree decodes body into a tree under a synthetic document node.
+func parseXMLTree(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return root, nil
+}
+
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -636,11 +636,20 @@ const App = () => {
         try {
           // Use adminKey if available, otherwise readKey
           const key = adminKey || config.readKey;
-          const res = await fetch(`${config.apiBase}/monitor`, {
-            headers: { 'Authorization': key }
-          });
-          if (!res.ok) throw new Error('API Error');
-          const data = await res.json();
+          // The API returns at most 200 monitors per page and the total in
+          // X-Total-Count; keep fetching until every monitor is loaded.
+          const pageSize = 200;
+          const data: any[] = [];
+          for (let page = 1; ; page++) {
+            const res = await fetch(`${config.apiBase}/monitor?page=${page}&page_size=${pageSize}`, {
+              headers: { 'Authorization': key }
+            });
+            if (!res.ok) throw new Error('API Error');
+            const batch = await res.json();
+            data.push(...batch);
+            const total = res.headers.get('X-Total-Count');
+            if (batch.length < pageSize || (total !== null && data.length >= Number(total))) break;
+          }
           const newServices: Service[] = data.map((item: any) => {
              const appStatus = mapApiStatusToAppStatus(item.status);
              const latencyFromApi = typeof item.last_response_time_ms === 'number' ? item.last_response_time_ms : 0;
@@ -755,7 +764,11 @@ const App = () => {
           headers: { 'Content-Type': 'application/json', 'Authorization': adminKey },
           body: JSON.stringify(payload)
         });
-        if (!res.ok) throw new Error("Failed");
+        if (!res.ok) {
+          const body = await res.json().catch(() => ({}));
+          alert(body.message || "Operation Failed");
+          return;
+        }
         setLogs(prev => [...prev, { timestamp: new Date().toLocaleTimeString(), message: { en: `SERVICE ${editorMode === 'CREATE' ? 'DEPLOYED' : 'UPDATED'}`, zh: `服务已${editorMode === 'CREATE' ? '部署' : '更新'}` }, type: 'INFO' }]);
       } catch(e) {
         alert("Operation Failed");
@@ -902,7 +915,7 @@ const App = () => {
           label={t.type}
           value={formData.region}
           onChange={(e: any) => setFormData({...formData, region: e.target.value})}
-          placeholder="e.g. API"
+          placeholder="http, https, tcp, udp, ping, db, feed, transaction"
         />
         <SciFiInput
           label={t.endpoint}